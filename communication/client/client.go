@@ -41,6 +41,12 @@ func (c *client) GetToken() (string, error) {
 	return string(body), nil
 }
 
+// setAuthorization passes the token as bearer token instead of a query
+// parameter, so it does not end up in access logs.
+func setAuthorization(req *http.Request, token string) {
+	req.Header.Set("Authorization", "Bearer "+token)
+}
+
 type TestCaseParams struct {
 	Stage    string
 	Testcase string
@@ -48,12 +54,13 @@ type TestCaseParams struct {
 }
 
 func (c *client) GetTestCase(params TestCaseParams, v any) (string, error) {
-	requestURL := fmt.Sprintf("%s/stage/%s/testcase/%s?token=%s", c.baseUrl, params.Stage, params.Testcase, params.Token)
+	requestURL := fmt.Sprintf("%s/stage/%s/testcase/%s", c.baseUrl, params.Stage, params.Testcase)
 	req, err := http.NewRequest(http.MethodGet, requestURL, nil)
 	if err != nil {
 		log.Error().Err(err).Msg("Could not create token request")
 		return "", err
 	}
+	setAuthorization(req, params.Token)
 
 	res, err := http.DefaultClient.Do(req)
 	if err != nil {
@@ -83,7 +90,7 @@ type SolutionResult struct {
 
 func (c *client) SubmitSolution(params TestCaseParams, solution any) (SolutionResult, error) {
 
-	requestURL := fmt.Sprintf("%s/stage/%s/testcase/%s?token=%s", c.baseUrl, params.Stage, params.Testcase, params.Token)
+	requestURL := fmt.Sprintf("%s/stage/%s/testcase/%s", c.baseUrl, params.Stage, params.Testcase)
 
 	reqBody, err := json.Marshal(solution)
 
@@ -99,6 +106,7 @@ func (c *client) SubmitSolution(params TestCaseParams, solution any) (SolutionRe
 		return SolutionResult{}, err
 	}
 	req.Header.Set("Content-Type", "application/json")
+	setAuthorization(req, params.Token)
 
 	res, err := http.DefaultClient.Do(req)
 	if err != nil {
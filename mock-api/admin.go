@@ -0,0 +1,246 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Fancy11111/ase-prep/mock-api/progress"
+	"github.com/Fancy11111/ase-prep/mock-api/roster"
+	"github.com/Fancy11111/ase-prep/mock-api/submission"
+	"github.com/rs/zerolog/log"
+)
+
+// requireAdmin only lets requests through that present the admin secret,
+// either as bearer token or as password of HTTP basic auth. The latter lets
+// browsers access admin pages. Without a configured secret the admin
+// endpoints are disabled.
+func (h Handler) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.adminSecret == "" {
+			writeProblem(w, r, http.StatusForbidden, codeAdminDisabled, "No admin secret is configured")
+			return
+		}
+
+		secret := tokenFromRequest(r, false)
+		if _, password, ok := r.BasicAuth(); ok {
+			secret = password
+		}
+
+		if subtle.ConstantTimeCompare([]byte(secret), []byte(h.adminSecret)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="admin"`)
+			writeProblem(w, r, http.StatusUnauthorized, codeInvalidAdminSecret, "Invalid admin secret")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeJSON answers with v encoded as JSON.
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, v any) {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		log.Ctx(r.Context()).Err(err).Msg("Could not marshal response")
+		writeProblem(w, r, http.StatusInternalServerError, codeInternal, "Could not encode response")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(encoded)
+}
+
+func (h Handler) reloadRoster(w http.ResponseWriter, r *http.Request) {
+	err := h.roster.Reload()
+	switch {
+	case errors.Is(err, roster.ErrNoFile):
+		writeProblem(w, r, http.StatusNotFound, codeNoRoster, "No roster file is configured")
+		return
+	case err != nil:
+		log.Ctx(r.Context()).Err(err).Msg("Could not reload roster")
+		writeProblem(w, r, http.StatusUnprocessableEntity, codeInvalidRoster, err.Error())
+		return
+	}
+
+	log.Ctx(r.Context()).Info().Int("students", h.roster.Len()).Msg("Reloaded roster")
+	writeJSON(w, r, http.StatusOK, map[string]int{"students": h.roster.Len()})
+}
+
+// importRoster replaces the roster with the CSV in the request body.
+func (h Handler) importRoster(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	if err := h.roster.Import(http.MaxBytesReader(w, r.Body, h.maxBodyBytes)); err != nil {
+		log.Ctx(r.Context()).Err(err).Msg("Could not import roster")
+		writeProblem(w, r, http.StatusUnprocessableEntity, codeInvalidRoster, err.Error())
+		return
+	}
+
+	log.Ctx(r.Context()).Info().Int("students", h.roster.Len()).Msg("Imported roster")
+	writeJSON(w, r, http.StatusOK, map[string]int{"students": h.roster.Len()})
+}
+
+type studentReport struct {
+	Mnr      string            `json:"mnr"`
+	Name     string            `json:"name,omitempty"`
+	Group    string            `json:"group,omitempty"`
+	OnRoster bool              `json:"onRoster"`
+	Progress progress.Progress `json:"progress"`
+}
+
+type studentsReport struct {
+	Summary  map[progress.Status]int `json:"summary"`
+	Students []studentReport         `json:"students"`
+}
+
+// studentReports joins the roster with the recorded progress. Students that
+// are not on the roster but used the mock anyway are included as well.
+func (h Handler) studentReports() []studentReport {
+	var reports []studentReport
+	seen := map[string]bool{}
+	for _, s := range h.roster.Students() {
+		seen[s.Mnr] = true
+		reports = append(reports, studentReport{
+			Mnr:      s.Mnr,
+			Name:     s.Name,
+			Group:    s.Group,
+			OnRoster: true,
+			Progress: h.progress.Get(s.Mnr),
+		})
+	}
+
+	for _, p := range h.progress.All() {
+		if seen[p.Mnr] {
+			continue
+		}
+		reports = append(reports, studentReport{Mnr: p.Mnr, Progress: p})
+	}
+
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].Mnr < reports[j].Mnr
+	})
+	return reports
+}
+
+// listStudents lists all students, optionally filtered by the status and
+// group query parameters.
+func (h Handler) listStudents(w http.ResponseWriter, r *http.Request) {
+	status := progress.Status(r.URL.Query().Get("status"))
+	group := r.URL.Query().Get("group")
+
+	switch status {
+	case "", progress.NotStarted, progress.Started, progress.Finished:
+	default:
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidFilter,
+			fmt.Sprintf("Unknown status %q, use %s, %s or %s", status, progress.NotStarted, progress.Started, progress.Finished))
+		return
+	}
+
+	report := studentsReport{
+		Summary: map[progress.Status]int{
+			progress.NotStarted: 0,
+			progress.Started:    0,
+			progress.Finished:   0,
+		},
+		Students: []studentReport{},
+	}
+	for _, s := range h.studentReports() {
+		if group != "" && s.Group != group {
+			continue
+		}
+		report.Summary[s.Progress.Status]++
+		if status != "" && s.Progress.Status != status {
+			continue
+		}
+		report.Students = append(report.Students, s)
+	}
+
+	writeJSON(w, r, http.StatusOK, report)
+}
+
+func (h Handler) getStudent(w http.ResponseWriter, r *http.Request) {
+	mnr := r.PathValue("mnr")
+	student, onRoster := h.roster.Get(mnr)
+	p := h.progress.Get(mnr)
+
+	if !onRoster && p.Status == progress.NotStarted {
+		writeProblem(w, r, http.StatusNotFound, codeUnknownStudent, fmt.Sprintf("No student with mnr %q", mnr))
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, studentReport{
+		Mnr:      mnr,
+		Name:     student.Name,
+		Group:    student.Group,
+		OnRoster: onRoster,
+		Progress: p,
+	})
+}
+
+// listSubmissions lists the submission history, newest first. It can be
+// filtered by the mnr, stage, testcase, verdict, since (RFC 3339) and limit
+// query parameters.
+func (h Handler) listSubmissions(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := submission.Filter{
+		Mnr:     query.Get("mnr"),
+		Stage:   query.Get("stage"),
+		Verdict: submission.Verdict(query.Get("verdict")),
+		Limit:   100,
+	}
+
+	var err error
+	if v := query.Get("testcase"); v != "" {
+		if filter.Testcase, err = strconv.Atoi(v); err != nil {
+			writeProblem(w, r, http.StatusBadRequest, codeInvalidFilter, fmt.Sprintf("Invalid testcase %q", v))
+			return
+		}
+	}
+	if v := query.Get("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil || filter.Limit < 0 {
+			writeProblem(w, r, http.StatusBadRequest, codeInvalidFilter, fmt.Sprintf("Invalid limit %q", v))
+			return
+		}
+	}
+	if v := query.Get("since"); v != "" {
+		if filter.Since, err = time.Parse(time.RFC3339, v); err != nil {
+			writeProblem(w, r, http.StatusBadRequest, codeInvalidFilter, fmt.Sprintf("Invalid since %q, expected RFC 3339", v))
+			return
+		}
+	}
+
+	submissions, err := h.submissions.List(r.Context(), filter)
+	if err != nil {
+		log.Ctx(r.Context()).Err(err).Msg("Could not list submissions")
+		writeProblem(w, r, http.StatusInternalServerError, codeInternal, "Could not list submissions")
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, submissions)
+}
+
+func (h Handler) getSubmission(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidFilter, fmt.Sprintf("Invalid submission id %q", r.PathValue("id")))
+		return
+	}
+
+	sub, err := h.submissions.Get(r.Context(), id)
+	switch {
+	case errors.Is(err, submission.ErrNotFound):
+		writeProblem(w, r, http.StatusNotFound, codeUnknownSubmission, fmt.Sprintf("No submission with id %d", id))
+		return
+	case err != nil:
+		log.Ctx(r.Context()).Err(err).Msg("Could not get submission")
+		writeProblem(w, r, http.StatusInternalServerError, codeInternal, "Could not get submission")
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, sub)
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+const bearerPrefix = "Bearer "
+
+// tokenFromRequest extracts the assignment token of a request.
+// The Authorization header is preferred, the token query parameter is only
+// consulted if allowQuery is set.
+func tokenFromRequest(r *http.Request, allowQuery bool) string {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		if len(auth) > len(bearerPrefix) && strings.EqualFold(auth[:len(bearerPrefix)], bearerPrefix) {
+			return strings.TrimSpace(auth[len(bearerPrefix):])
+		}
+		return ""
+	}
+
+	if allowQuery {
+		return r.URL.Query().Get("token")
+	}
+	return ""
+}
@@ -0,0 +1,69 @@
+// webhook-receiver is a local endpoint to try the webhooks of the mock-api
+// with. It verifies the signatures and prints the received payloads.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/Fancy11111/ase-prep/mock-api/webhook"
+)
+
+// maxSkew is how old a timestamp may be, older requests could be replayed.
+const maxSkew = 5 * time.Minute
+
+func main() {
+	listen := flag.String("listen", ":4000", "address to listen on")
+	secret := flag.String("secret", "", "secret the payloads are signed with")
+	fail := flag.Int("fail", 0, "answer the first n requests with 503 to try the retries")
+	flag.Parse()
+
+	if *secret == "" {
+		fmt.Fprintln(os.Stderr, "-secret is required")
+		os.Exit(2)
+	}
+
+	var received atomic.Int64
+	http.HandleFunc("POST /", func(w http.ResponseWriter, r *http.Request) {
+		n := received.Add(1)
+		if n <= int64(*fail) {
+			fmt.Printf("#%d failing on purpose\n", n)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		timestamp := r.Header.Get(webhook.HeaderTimestamp)
+		unix, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil || time.Since(time.Unix(unix, 0)).Abs() > maxSkew {
+			fmt.Printf("#%d rejected, stale or missing timestamp %q\n", n, timestamp)
+			http.Error(w, "stale timestamp", http.StatusUnauthorized)
+			return
+		}
+		if !webhook.Verify(*secret, timestamp, body, r.Header.Get(webhook.HeaderSignature)) {
+			fmt.Printf("#%d rejected, invalid signature\n", n)
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+
+		fmt.Printf("#%d %s %s %s\n", n, r.Header.Get(webhook.HeaderEvent), r.Header.Get(webhook.HeaderID), body)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	fmt.Printf("Listening on %s\n", *listen)
+	if err := http.ListenAndServe(*listen, nil); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
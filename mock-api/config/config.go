@@ -0,0 +1,444 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/Fancy11111/ase-prep/mock-api/leaderboard"
+	"github.com/Fancy11111/ase-prep/mock-api/stage"
+	"github.com/Fancy11111/ase-prep/mock-api/token"
+	"gopkg.in/yaml.v3"
+)
+
+type Config struct {
+	// Listen is the address the HTTP server binds to, e.g. ":3000".
+	Listen string `yaml:"listen"`
+	// PublicURL is the base URL clients reach the mock under, used for links.
+	PublicURL string `yaml:"publicUrl"`
+	// TrustForwardedHeaders derives the base URL of links from the
+	// X-Forwarded-Proto and X-Forwarded-Host headers set by a reverse proxy.
+	TrustForwardedHeaders bool `yaml:"trustForwardedHeaders"`
+	// ShutdownTimeout bounds how long in-flight requests may take to
+	// complete once the server is asked to stop.
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout"`
+	// ShutdownDelay keeps serving after the server reported not ready on
+	// /readyz, so load balancers stop routing to it before it drains.
+	ShutdownDelay time.Duration `yaml:"shutdownDelay"`
+
+	Token       TokenConfig       `yaml:"token"`
+	Students    StudentsConfig    `yaml:"students"`
+	Stages      []StageConfig     `yaml:"stages"`
+	Submissions SubmissionsConfig `yaml:"submissions"`
+	Leaderboard LeaderboardConfig `yaml:"leaderboard"`
+	Webhooks    WebhooksConfig    `yaml:"webhooks"`
+	Limits      LimitsConfig      `yaml:"limits"`
+	Telemetry   TelemetryConfig   `yaml:"telemetry"`
+	Admin       AdminConfig       `yaml:"admin"`
+
+	// StageDir holds the file stages, one directory with a manifest.yaml
+	// per stage, which are served after Stages.
+	StageDir string `yaml:"stageDir"`
+	// CasesDir holds <kind>.json files with curated testcases, replacing
+	// the built-in ones of their stage kind.
+	CasesDir string `yaml:"casesDir"`
+
+	// PrintConfig is only set via flag and makes the server print the
+	// effective configuration instead of starting.
+	PrintConfig bool `yaml:"-"`
+}
+
+type TokenConfig struct {
+	TTL        time.Duration `yaml:"ttl"`
+	Redaction  string        `yaml:"redaction"`
+	AllowQuery bool          `yaml:"allowQuery"`
+}
+
+type StudentsConfig struct {
+	// MnrPattern is the regular expression every matriculation number has to match.
+	MnrPattern string `yaml:"mnrPattern"`
+	// Roster is an optional CSV file listing the allowed matriculation numbers.
+	Roster string `yaml:"roster"`
+}
+
+type StageConfig struct {
+	ID   string `yaml:"id"`
+	Kind string `yaml:"kind"`
+	// Testcases is the number of generated testcases, curated ones are
+	// served in addition.
+	Testcases int `yaml:"testcases"`
+	// EdgeCaseInterval is how many generated testcases precede every
+	// curated one, 0 uses stage.DefaultEdgeCaseInterval and a negative
+	// interval serves no curated testcases.
+	EdgeCaseInterval int `yaml:"edgeCaseInterval"`
+}
+
+type SubmissionsConfig struct {
+	// Store is either "memory" or "sqlite".
+	Store string `yaml:"store"`
+	// Path is the database file of the sqlite store.
+	Path string `yaml:"path"`
+	// Strict rejects solutions with properties the solution schema of the
+	// stage does not declare.
+	Strict bool `yaml:"strict"`
+}
+
+type LeaderboardConfig struct {
+	// Public makes the leaderboard accessible without the admin secret.
+	Public bool `yaml:"public"`
+	// Anonymization is one of none, name, masked or pseudonym.
+	Anonymization string `yaml:"anonymization"`
+	// Salt for pseudonyms, a random one is used if empty so pseudonyms
+	// change on every restart.
+	Salt string `yaml:"salt"`
+}
+
+type WebhooksConfig struct {
+	Endpoints []WebhookConfig `yaml:"endpoints"`
+	// MaxAttempts is how often a delivery is tried before it is written to
+	// the dead letter log.
+	MaxAttempts int `yaml:"maxAttempts"`
+	// Backoff is the wait before the first retry, it doubles with every
+	// further attempt.
+	Backoff time.Duration `yaml:"backoff"`
+	// Timeout bounds a single delivery attempt.
+	Timeout time.Duration `yaml:"timeout"`
+	// DeadLetter is the JSON lines file failed deliveries are appended to.
+	DeadLetter string `yaml:"deadLetter"`
+}
+
+type WebhookConfig struct {
+	URL string `yaml:"url"`
+	// Secret is the key of the HMAC-SHA256 signature of the payloads.
+	Secret string `yaml:"secret"`
+	// Accepted also notifies about every accepted submission, not only
+	// about finished assignments.
+	Accepted bool `yaml:"accepted"`
+}
+
+type LimitsConfig struct {
+	MaxBodyBytes      int64         `yaml:"maxBodyBytes"`
+	ReadHeaderTimeout time.Duration `yaml:"readHeaderTimeout"`
+}
+
+type TelemetryConfig struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+type AdminConfig struct {
+	Secret string `yaml:"secret"`
+}
+
+// Default returns the configuration used if nothing else is configured.
+func Default() Config {
+	return Config{
+		Listen:          ":3000",
+		PublicURL:       "http://localhost:3000",
+		ShutdownTimeout: 15 * time.Second,
+		Token: TokenConfig{
+			TTL:        10 * time.Minute,
+			Redaction:  token.RedactHash.String(),
+			AllowQuery: true,
+		},
+		Students: StudentsConfig{
+			MnrPattern: `^[0-9]{8}$`,
+		},
+		Stages: []StageConfig{
+			{ID: "1", Kind: "points-c", Testcases: 10},
+		},
+		Submissions: SubmissionsConfig{
+			Store:  "memory",
+			Path:   "submissions.db",
+			Strict: true,
+		},
+		Leaderboard: LeaderboardConfig{
+			Public:        true,
+			Anonymization: string(leaderboard.Pseudonym),
+		},
+		Webhooks: WebhooksConfig{
+			MaxAttempts: 5,
+			Backoff:     time.Second,
+			Timeout:     10 * time.Second,
+			DeadLetter:  "webhooks-dead-letter.jsonl",
+		},
+		Limits: LimitsConfig{
+			MaxBodyBytes:      1 << 20,
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+	}
+}
+
+// Load builds the configuration from the defaults, an optional YAML file,
+// environment variables and command line flags, each overriding the previous.
+// The file is taken from the -config flag or the CONFIG_FILE variable.
+func Load(args []string, getenv func(string) string) (Config, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet("mock-api", flag.ContinueOnError)
+	path := fs.String("config", getenv("CONFIG_FILE"), "path to a YAML config file")
+	listen := fs.String("listen", "", "address to listen on")
+	publicURL := fs.String("public-url", "", "public base URL used in generated links")
+	trustForwarded := fs.Bool("trust-forwarded-headers", false, "derive link base URLs from X-Forwarded-* headers")
+	shutdownTimeout := fs.Duration("shutdown-timeout", 0, "time in-flight requests get to complete on shutdown")
+	shutdownDelay := fs.Duration("shutdown-delay", 0, "time the server keeps serving while reporting not ready on shutdown")
+	tokenTTL := fs.Duration("token-ttl", 0, "validity of issued tokens")
+	adminSecret := fs.String("admin-secret", "", "secret protecting the admin endpoints")
+	rosterFile := fs.String("roster", "", "CSV file with the matriculation numbers allowed to use the mock")
+	fs.BoolVar(&cfg.PrintConfig, "print-config", false, "print the effective configuration and exit")
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	if *path != "" {
+		if err := cfg.loadFile(*path); err != nil {
+			return cfg, err
+		}
+	}
+
+	if err := cfg.applyEnv(getenv); err != nil {
+		return cfg, err
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "listen":
+			cfg.Listen = *listen
+		case "public-url":
+			cfg.PublicURL = *publicURL
+		case "trust-forwarded-headers":
+			cfg.TrustForwardedHeaders = *trustForwarded
+		case "shutdown-timeout":
+			cfg.ShutdownTimeout = *shutdownTimeout
+		case "shutdown-delay":
+			cfg.ShutdownDelay = *shutdownDelay
+		case "token-ttl":
+			cfg.Token.TTL = *tokenTTL
+		case "admin-secret":
+			cfg.Admin.Secret = *adminSecret
+		case "roster":
+			cfg.Students.Roster = *rosterFile
+		}
+	})
+
+	return cfg, cfg.Validate()
+}
+
+func (c *Config) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open config file: %w", err)
+	}
+	defer f.Close()
+
+	decoder := yaml.NewDecoder(f)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c *Config) applyEnv(getenv func(string) string) error {
+	var errs []error
+
+	str := func(name string, target *string) {
+		if v := getenv(name); v != "" {
+			*target = v
+		}
+	}
+	boolean := func(name string, target *bool) {
+		if v := getenv(name); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+				return
+			}
+			*target = b
+		}
+	}
+	duration := func(name string, target *time.Duration) {
+		if v := getenv(name); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+				return
+			}
+			*target = d
+		}
+	}
+
+	// ADDR and PORT are kept for compatibility and replace the host
+	// respectively port part of the listen address.
+	if addr, port := getenv("ADDR"), getenv("PORT"); addr != "" || port != "" {
+		host, listenPort, err := net.SplitHostPort(c.Listen)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("listen: %w", err))
+		} else {
+			if addr != "" {
+				host = addr
+			}
+			if port != "" {
+				listenPort = port
+			}
+			c.Listen = net.JoinHostPort(host, listenPort)
+		}
+	}
+
+	str("LISTEN_ADDR", &c.Listen)
+	str("PUBLIC_URL", &c.PublicURL)
+	boolean("TRUST_FORWARDED_HEADERS", &c.TrustForwardedHeaders)
+	duration("SHUTDOWN_TIMEOUT", &c.ShutdownTimeout)
+	duration("SHUTDOWN_DELAY", &c.ShutdownDelay)
+	duration("TOKEN_TTL", &c.Token.TTL)
+	str("TOKEN_REDACTION", &c.Token.Redaction)
+	boolean("ALLOW_QUERY_TOKEN", &c.Token.AllowQuery)
+	str("MNR_PATTERN", &c.Students.MnrPattern)
+	str("ROSTER_FILE", &c.Students.Roster)
+	str("STAGE_DIR", &c.StageDir)
+	str("CASES_DIR", &c.CasesDir)
+	str("SUBMISSION_STORE", &c.Submissions.Store)
+	str("SUBMISSION_DB", &c.Submissions.Path)
+	boolean("STRICT_SOLUTIONS", &c.Submissions.Strict)
+	boolean("LEADERBOARD_PUBLIC", &c.Leaderboard.Public)
+	str("LEADERBOARD_ANONYMIZATION", &c.Leaderboard.Anonymization)
+	str("LEADERBOARD_SALT", &c.Leaderboard.Salt)
+	str("WEBHOOK_DEAD_LETTER", &c.Webhooks.DeadLetter)
+	boolean("TELEMETRY_ENABLED", &c.Telemetry.Enabled)
+	str("ADMIN_SECRET", &c.Admin.Secret)
+
+	if v := getenv("MAX_BODY_BYTES"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("MAX_BODY_BYTES: %w", err))
+		} else {
+			c.Limits.MaxBodyBytes = n
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Validate reports all problems of the configuration at once.
+func (c Config) Validate() error {
+	var errs []error
+
+	if _, _, err := net.SplitHostPort(c.Listen); err != nil {
+		errs = append(errs, fmt.Errorf("listen: %w", err))
+	}
+
+	if u, err := url.Parse(c.PublicURL); err != nil {
+		errs = append(errs, fmt.Errorf("publicUrl: %w", err))
+	} else if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, fmt.Errorf("publicUrl: %q must be an absolute http(s) URL", c.PublicURL))
+	}
+
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("shutdownTimeout: must be positive, got %s", c.ShutdownTimeout))
+	}
+	if c.ShutdownDelay < 0 {
+		errs = append(errs, fmt.Errorf("shutdownDelay: must not be negative, got %s", c.ShutdownDelay))
+	}
+
+	if c.Token.TTL <= 0 {
+		errs = append(errs, fmt.Errorf("token.ttl: must be positive, got %s", c.Token.TTL))
+	}
+	if _, err := token.ParseRedactionMode(c.Token.Redaction); err != nil {
+		errs = append(errs, fmt.Errorf("token.redaction: %w", err))
+	}
+
+	if _, err := regexp.Compile(c.Students.MnrPattern); err != nil {
+		errs = append(errs, fmt.Errorf("students.mnrPattern: %w", err))
+	}
+
+	if len(c.Stages) == 0 && c.StageDir == "" {
+		errs = append(errs, errors.New("stages: at least one stage or a stageDir is required"))
+	}
+	ids := map[string]bool{}
+	for i, s := range c.Stages {
+		if s.ID == "" {
+			errs = append(errs, fmt.Errorf("stages[%d].id: must not be empty", i))
+		} else if ids[s.ID] {
+			errs = append(errs, fmt.Errorf("stages[%d].id: duplicate id %q", i, s.ID))
+		}
+		ids[s.ID] = true
+		if !slices.Contains(stage.Kinds(), s.Kind) {
+			errs = append(errs, fmt.Errorf("stages[%d].kind: unknown stage kind %q, known kinds are %v", i, s.Kind, stage.Kinds()))
+		}
+		if s.Testcases < 1 {
+			errs = append(errs, fmt.Errorf("stages[%d].testcases: must be at least 1", i))
+		} else if limit := stage.MaxTestcases(s.Kind); s.Testcases > limit {
+			errs = append(errs, fmt.Errorf("stages[%d].testcases: %s stages may have at most %d", i, s.Kind, limit))
+		}
+	}
+
+	switch c.Submissions.Store {
+	case "memory":
+	case "sqlite":
+		if c.Submissions.Path == "" {
+			errs = append(errs, errors.New("submissions.path: required for the sqlite store"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("submissions.store: unknown store %q, use memory or sqlite", c.Submissions.Store))
+	}
+
+	if _, err := leaderboard.ParseAnonymization(c.Leaderboard.Anonymization); err != nil {
+		errs = append(errs, fmt.Errorf("leaderboard.anonymization: %w", err))
+	}
+
+	for i, e := range c.Webhooks.Endpoints {
+		if u, err := url.Parse(e.URL); err != nil {
+			errs = append(errs, fmt.Errorf("webhooks.endpoints[%d].url: %w", i, err))
+		} else if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("webhooks.endpoints[%d].url: %q must be an absolute http(s) URL", i, e.URL))
+		}
+		if e.Secret == "" {
+			errs = append(errs, fmt.Errorf("webhooks.endpoints[%d].secret: must not be empty", i))
+		}
+	}
+	if c.Webhooks.MaxAttempts < 1 {
+		errs = append(errs, errors.New("webhooks.maxAttempts: must be at least 1"))
+	}
+	if c.Webhooks.Backoff <= 0 {
+		errs = append(errs, errors.New("webhooks.backoff: must be positive"))
+	}
+	if c.Webhooks.Timeout <= 0 {
+		errs = append(errs, errors.New("webhooks.timeout: must be positive"))
+	}
+
+	if c.Limits.MaxBodyBytes <= 0 {
+		errs = append(errs, errors.New("limits.maxBodyBytes: must be positive"))
+	}
+	if c.Limits.ReadHeaderTimeout <= 0 {
+		errs = append(errs, errors.New("limits.readHeaderTimeout: must be positive"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Print writes the configuration as YAML, with secrets masked.
+func (c Config) Print(w io.Writer) error {
+	if c.Admin.Secret != "" {
+		c.Admin.Secret = "********"
+	}
+	if c.Leaderboard.Salt != "" {
+		c.Leaderboard.Salt = "********"
+	}
+	endpoints := make([]WebhookConfig, len(c.Webhooks.Endpoints))
+	for i, e := range c.Webhooks.Endpoints {
+		e.Secret = "********"
+		endpoints[i] = e
+	}
+	c.Webhooks.Endpoints = endpoints
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	defer encoder.Close()
+	return encoder.Encode(c)
+}
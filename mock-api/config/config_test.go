@@ -0,0 +1,107 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDefaultIsValid(t *testing.T) {
+	if err := Default().Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+}
+
+func TestValidateReportsAllProblems(t *testing.T) {
+	cfg := Default()
+	cfg.PublicURL = "localhost:3000"
+	cfg.Stages = []StageConfig{
+		{ID: "1", Kind: "points-c", Testcases: 13},
+		{ID: "1", Kind: "unknown", Testcases: 0},
+	}
+	cfg.Submissions.Store = "files"
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Validate() = nil")
+	}
+	for _, want := range []string{
+		"publicUrl:",
+		"stages[0].testcases: points-c stages may have at most 12",
+		`stages[1].id: duplicate id "1"`,
+		`stages[1].kind: unknown stage kind "unknown"`,
+		"stages[1].testcases: must be at least 1",
+		`submissions.store: unknown store "files"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate() = %v, want it to contain %q", err, want)
+		}
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	file := "listen: :4000\npublicUrl: https://file.example\ntoken:\n  ttl: 1m\n"
+	if err := os.WriteFile(path, []byte(file), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]string{
+		"CONFIG_FILE": path,
+		"LISTEN_ADDR": ":5000",
+		"PUBLIC_URL":  "https://env.example",
+	}
+
+	cfg, err := Load([]string{"-listen", ":6000"}, func(name string) string { return env[name] })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Token.TTL != time.Minute {
+		t.Errorf("token TTL = %s, want the 1m of the file", cfg.Token.TTL)
+	}
+	if cfg.PublicURL != "https://env.example" {
+		t.Errorf("public URL = %s, want the one of the environment", cfg.PublicURL)
+	}
+	if cfg.Listen != ":6000" {
+		t.Errorf("listen = %s, want the one of the flag", cfg.Listen)
+	}
+	if cfg.ShutdownTimeout != Default().ShutdownTimeout {
+		t.Errorf("shutdown timeout = %s, want the default", cfg.ShutdownTimeout)
+	}
+}
+
+func TestLoadRejectsUnknownFields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("lisen: :4000\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Load([]string{"-config", path}, func(string) string { return "" }); err == nil {
+		t.Error("Load() accepted an unknown field")
+	}
+}
+
+func TestPrintMasksSecrets(t *testing.T) {
+	cfg := Default()
+	cfg.Admin.Secret = "admin-secret"
+	cfg.Leaderboard.Salt = "leaderboard-salt"
+	cfg.Webhooks.Endpoints = []WebhookConfig{{URL: "https://hooks.example", Secret: "webhook-secret"}}
+
+	var out bytes.Buffer
+	if err := cfg.Print(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"admin-secret", "leaderboard-salt", "webhook-secret"} {
+		if strings.Contains(out.String(), secret) {
+			t.Errorf("printed configuration contains %s:\n%s", secret, out.String())
+		}
+	}
+	if !strings.Contains(out.String(), "https://hooks.example") {
+		t.Errorf("printed configuration lacks the webhook URL:\n%s", out.String())
+	}
+	if cfg.Webhooks.Endpoints[0].Secret != "webhook-secret" {
+		t.Error("Print changed the webhook secret of the configuration")
+	}
+}
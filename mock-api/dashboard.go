@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Fancy11111/ase-prep/mock-api/submission"
+	"github.com/Fancy11111/ase-prep/mock-api/token"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	dashboardInterval    = 2 * time.Second
+	dashboardSubmissions = 25
+	recentProblemsSize   = 50
+)
+
+type recentProblem struct {
+	Time      time.Time `json:"time"`
+	Status    int       `json:"status"`
+	Code      string    `json:"code"`
+	Detail    string    `json:"detail,omitempty"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	RequestID string    `json:"requestId,omitempty"`
+}
+
+// problemLog is a ring buffer of the most recent problems.
+type problemLog struct {
+	mu      sync.Mutex
+	entries []recentProblem
+	next    int
+	full    bool
+}
+
+func newProblemLog(size int) *problemLog {
+	return &problemLog{entries: make([]recentProblem, size)}
+}
+
+// withProblemLog makes writeProblem record the problems of a request in
+// the handler's log.
+func (h Handler) withProblemLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), problemLogKey, h.problems)))
+	})
+}
+
+func (l *problemLog) add(p recentProblem) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries[l.next] = p
+	l.next = (l.next + 1) % len(l.entries)
+	if l.next == 0 {
+		l.full = true
+	}
+}
+
+// recent returns the logged problems, newest first.
+func (l *problemLog) recent() []recentProblem {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	n := l.next
+	if l.full {
+		n = len(l.entries)
+	}
+	result := make([]recentProblem, 0, n)
+	for i := 1; i <= n; i++ {
+		result = append(result, l.entries[(l.next-i+len(l.entries))%len(l.entries)])
+	}
+	return result
+}
+
+type passRate struct {
+	Stage       string  `json:"stage"`
+	Submissions int     `json:"submissions"`
+	Accepted    int     `json:"accepted"`
+	Rejected    int     `json:"rejected"`
+	Malformed   int     `json:"malformed"`
+	Rate        float64 `json:"rate"`
+}
+
+type dashboardSnapshot struct {
+	GeneratedAt  time.Time               `json:"generatedAt"`
+	ActiveTokens []token.ActiveToken     `json:"activeTokens"`
+	Submissions  []submission.Submission `json:"submissions"`
+	PassRates    []passRate              `json:"passRates"`
+	Errors       []recentProblem         `json:"errors"`
+}
+
+func (h Handler) getDashboard(w http.ResponseWriter, r *http.Request) {
+	page, err := templates.ReadFile("templates/dashboard.html")
+	if err != nil {
+		log.Ctx(r.Context()).Err(err).Msg("Could not read dashboard")
+		writeProblem(w, r, http.StatusInternalServerError, codeInternal, "Could not load dashboard")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(page)
+}
+
+// dashboardEvents streams a snapshot of the mock's state every
+// dashboardInterval until the client disconnects or the server shuts down.
+func (h Handler) dashboardEvents(w http.ResponseWriter, r *http.Request) {
+	ticker := time.NewTicker(dashboardInterval)
+	defer ticker.Stop()
+
+	startEventStream(w)
+	for {
+		snapshot, err := h.dashboardSnapshot(r)
+		if err != nil {
+			log.Ctx(r.Context()).Err(err).Msg("Could not create dashboard snapshot")
+		} else if err := writeEvent(w, "", "snapshot", snapshot); err != nil {
+			log.Ctx(r.Context()).Debug().Err(err).Msg("Dashboard stream closed")
+			return
+		}
+
+		select {
+		case <-ticker.C:
+		case <-r.Context().Done():
+			return
+		case <-h.state.done():
+			return
+		}
+	}
+}
+
+func (h Handler) dashboardSnapshot(r *http.Request) (dashboardSnapshot, error) {
+	submissions, err := h.submissions.List(r.Context(), submission.Filter{Limit: dashboardSubmissions})
+	if err != nil {
+		return dashboardSnapshot{}, err
+	}
+	counts, err := h.submissions.CountVerdicts(r.Context())
+	if err != nil {
+		return dashboardSnapshot{}, err
+	}
+
+	snapshot := dashboardSnapshot{
+		GeneratedAt:  time.Now(),
+		ActiveTokens: h.tm.Active(),
+		Submissions:  []submission.Submission{},
+		PassRates:    h.passRates(counts),
+		Errors:       h.problems.recent(),
+	}
+	for _, s := range submissions {
+		// The payloads would only bloat the stream.
+		s.Payload = nil
+		snapshot.Submissions = append(snapshot.Submissions, s)
+	}
+	return snapshot, nil
+}
+
+// passRates computes the share of accepted submissions per registered stage.
+func (h Handler) passRates(counts map[string]submission.VerdictCounts) []passRate {
+	entries := h.stages.List()
+	rates := make([]passRate, len(entries))
+	for i, e := range entries {
+		c := counts[e.ID]
+		rates[i] = passRate{
+			Stage:     e.ID,
+			Accepted:  c[submission.Accepted],
+			Rejected:  c[submission.Rejected],
+			Malformed: c[submission.Malformed],
+		}
+		for _, n := range c {
+			rates[i].Submissions += n
+		}
+		if rates[i].Submissions > 0 {
+			rates[i].Rate = float64(rates[i].Accepted) / float64(rates[i].Submissions)
+		}
+	}
+	return rates
+}
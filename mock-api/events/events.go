@@ -0,0 +1,155 @@
+package events
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Type identifies what happened in an Event.
+type Type string
+
+const (
+	TokenIssued       Type = "token-issued"
+	TestcaseFetched   Type = "testcase-fetched"
+	SolutionSubmitted Type = "solution-submitted"
+	Finished          Type = "finished"
+)
+
+var types = []Type{TokenIssued, TestcaseFetched, SolutionSubmitted, Finished}
+
+// ParseType accepts the names of the event types.
+func ParseType(s string) (Type, error) {
+	t := Type(s)
+	if !slices.Contains(types, t) {
+		return "", fmt.Errorf("unknown event type %q, expected one of %v", s, types)
+	}
+	return t, nil
+}
+
+// Event is something a student did. Stage and Testcase are only set for
+// events concerning a testcase, Submission and Verdict only for submitted
+// solutions.
+type Event struct {
+	ID         int64     `json:"id"`
+	Type       Type      `json:"type"`
+	Time       time.Time `json:"time"`
+	Mnr        string    `json:"mnr"`
+	Stage      string    `json:"stage,omitempty"`
+	Testcase   int       `json:"testcase,omitempty"`
+	Submission int64     `json:"submission,omitempty"`
+	Verdict    string    `json:"verdict,omitempty"`
+}
+
+// Filter selects events, empty fields match everything.
+type Filter struct {
+	Mnr   string
+	Stage string
+	Types []Type
+}
+
+func (f Filter) Matches(e Event) bool {
+	if f.Mnr != "" && e.Mnr != f.Mnr {
+		return false
+	}
+	if f.Stage != "" && e.Stage != f.Stage {
+		return false
+	}
+	if len(f.Types) > 0 && !slices.Contains(f.Types, e.Type) {
+		return false
+	}
+	return true
+}
+
+// Bus delivers published events to all matching subscriptions. Publishing
+// never blocks: a subscriber that does not keep up loses events, which are
+// counted in Subscription.Dropped, unless it handles them as overflow.
+type Bus struct {
+	mu            sync.RWMutex
+	subscriptions map[*Subscription]struct{}
+	nextID        atomic.Int64
+}
+
+func NewBus() *Bus {
+	return &Bus{subscriptions: map[*Subscription]struct{}{}}
+}
+
+// Publish assigns e an ID, and the current time if it has none, and hands
+// it to the subscribers.
+func (b *Bus) Publish(e Event) {
+	e.ID = b.nextID.Add(1)
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for s := range b.subscriptions {
+		if !s.filter.Matches(e) {
+			continue
+		}
+		select {
+		case s.events <- e:
+		default:
+			if s.overflow != nil {
+				s.overflow(e)
+			} else {
+				s.dropped.Add(1)
+			}
+		}
+	}
+}
+
+// Subscribe receives the events matching filter from now on, buffering up
+// to buffer of them. The subscription has to be closed when no longer used.
+func (b *Bus) Subscribe(filter Filter, buffer int) *Subscription {
+	return b.SubscribeWithOverflow(filter, buffer, nil)
+}
+
+// SubscribeWithOverflow is Subscribe, but events that do not fit into the
+// buffer are passed to overflow instead of being dropped. overflow is called
+// by the publisher until the subscription is closed and should be quick.
+func (b *Bus) SubscribeWithOverflow(filter Filter, buffer int, overflow func(Event)) *Subscription {
+	s := &Subscription{
+		bus:      b,
+		filter:   filter,
+		events:   make(chan Event, buffer),
+		overflow: overflow,
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscriptions[s] = struct{}{}
+	return s
+}
+
+type Subscription struct {
+	bus      *Bus
+	filter   Filter
+	events   chan Event
+	overflow func(Event)
+	dropped  atomic.Int64
+	once     sync.Once
+}
+
+// Events is closed once the subscription is closed.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Dropped returns how many events were lost because the buffer was full.
+func (s *Subscription) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.bus.mu.Lock()
+		defer s.bus.mu.Unlock()
+		delete(s.bus.subscriptions, s)
+		close(s.events)
+	})
+}
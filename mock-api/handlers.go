@@ -0,0 +1,375 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Fancy11111/ase-prep/mock-api/events"
+	"github.com/Fancy11111/ase-prep/mock-api/progress"
+	"github.com/Fancy11111/ase-prep/mock-api/roster"
+	"github.com/Fancy11111/ase-prep/mock-api/stage"
+	"github.com/Fancy11111/ase-prep/mock-api/submission"
+	"github.com/Fancy11111/ase-prep/mock-api/token"
+	"github.com/rs/zerolog/log"
+)
+
+type Handler struct {
+	state           *lifecycle
+	tm              token.TokenManager
+	stages          *stage.Registry
+	links           linkBuilder
+	allowQueryToken bool
+	maxBodyBytes    int64
+	mnrPattern      *regexp.Regexp
+	roster          *roster.Roster
+	progress        *progress.Tracker
+	submissions     submission.Store
+	events          *events.Bus
+	problems        *problemLog
+	leaderboard     leaderboardOptions
+	adminSecret     string
+}
+
+func (h Handler) ping(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+
+	io.WriteString(w, "pong!")
+}
+
+func (h Handler) health(w http.ResponseWriter, r *http.Request) {
+	if h.state.isShuttingDown() {
+		writeProblem(w, r, http.StatusServiceUnavailable, codeShuttingDown, "Server is shutting down")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+
+	io.WriteString(w, "healthy")
+}
+
+// livez only tells whether the process is able to serve requests at all.
+func (h Handler) livez(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	io.WriteString(w, `{"status":"ok"}`)
+}
+
+func (h Handler) readyz(w http.ResponseWriter, r *http.Request) {
+	ready, report := h.state.readiness(r.Context())
+	if !ready {
+		log.Ctx(r.Context()).Warn().Any("checks", report.Checks).Msg("Not ready")
+	}
+
+	encoded, err := json.Marshal(report)
+	if err != nil {
+		log.Ctx(r.Context()).Err(err).Msg("Could not marshal readiness report")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if ready {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	w.Write(encoded)
+}
+
+func (h Handler) getToken(w http.ResponseWriter, r *http.Request) {
+	p := paramsFrom(r.Context())
+
+	token, created, err := h.tm.GetToken(p.mnr)
+	if err != nil {
+		log.Ctx(r.Context()).Err(err).Msg("Could not issue token")
+		writeProblem(w, r, http.StatusInternalServerError, codeInternal, "Could not issue token")
+		return
+	}
+	if created {
+		h.progress.TokenIssued(p.mnr)
+		h.events.Publish(events.Event{Type: events.TokenIssued, Mnr: p.mnr})
+	}
+
+	w.WriteHeader(http.StatusOK)
+	io.WriteString(w, token)
+}
+
+func (h Handler) resetToken(w http.ResponseWriter, r *http.Request) {
+	h.tm.ResetToken(paramsFrom(r.Context()).mnr)
+	w.WriteHeader(http.StatusOK)
+}
+
+// stageDocument describes a stage at /assignment/{mnr}/stage/{stage}.
+type stageDocument struct {
+	ID            string `json:"id"`
+	Kind          string `json:"kind"`
+	Testcases     int    `json:"testcases"`
+	FirstTestcase string `json:"firstTestcase"`
+	stage.Description
+}
+
+// getStage returns the problem statement, schemas and an example of a
+// stage, or only the statement if the client accepts text/markdown.
+func (h Handler) getStage(w http.ResponseWriter, r *http.Request) {
+	p := paramsFrom(r.Context())
+	description := p.entry.Runner.Describe()
+
+	if strings.Contains(r.Header.Get("Accept"), "text/markdown") {
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		io.WriteString(w, description.Statement)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, stageDocument{
+		ID:            p.entry.ID,
+		Kind:          p.entry.Kind,
+		Testcases:     p.entry.Testcases,
+		FirstTestcase: h.links.link(r, nil, "assignment", p.mnr, "stage", p.stage, "testcase", "1"),
+		Description:   description,
+	})
+}
+
+func (h Handler) getTestcase(w http.ResponseWriter, r *http.Request) {
+	p := paramsFrom(r.Context())
+
+	testcase, err := p.entry.Runner.Testcase(p.token, p.testcase)
+	if err != nil {
+		log.Ctx(r.Context()).Err(err).Msg("Could not create testcase")
+		writeProblem(w, r, http.StatusInternalServerError, codeInternal, "Could not create testcase")
+		return
+	}
+
+	encoded, err := json.Marshal(testcase)
+	if err != nil {
+		log.Ctx(r.Context()).Err(err).Msg("Could not marshal testcase")
+		writeProblem(w, r, http.StatusInternalServerError, codeInternal, "Could not create testcase")
+		return
+	}
+
+	log.Ctx(r.Context()).Debug().Str("encoded", string(encoded)).Msg("encoded testcase")
+	h.progress.TestcaseFetched(p.mnr, p.stage, p.testcase)
+	h.events.Publish(events.Event{Type: events.TestcaseFetched, Mnr: p.mnr, Stage: p.stage, Testcase: p.testcase})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(encoded)
+}
+
+func (h Handler) postTestResult(w http.ResponseWriter, r *http.Request) {
+	p := paramsFrom(r.Context())
+
+	defer r.Body.Close()
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.maxBodyBytes))
+
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		writeProblem(w, r, http.StatusRequestEntityTooLarge, codeSolutionTooLarge,
+			fmt.Sprintf("Solutions may not exceed %d bytes", maxBytesErr.Limit))
+		return
+	case err != nil:
+		log.Ctx(r.Context()).Err(err).Msg("Could not read solution")
+		writeProblem(w, r, http.StatusBadRequest, codeMalformedSolution, "Could not read solution")
+		return
+	}
+
+	correct, err := p.entry.Runner.Validate(p.token, p.testcase, bytes.NewReader(body))
+	h.recordSubmission(r, p, body, correct, err)
+	var schemaErr *stage.SchemaError
+	switch {
+	case errors.As(err, &schemaErr):
+		log.Ctx(r.Context()).Info().Err(err).Msg("Solution does not match schema")
+		writeProblemErrors(w, r, http.StatusBadRequest, codeMalformedSolution,
+			"Solution does not match the schema of the stage", schemaErr.Violations)
+		return
+	case err != nil:
+		log.Ctx(r.Context()).Err(err).Msg("Could not unmarshal solution")
+		writeProblem(w, r, http.StatusBadRequest, codeMalformedSolution, "Could not parse solution: "+err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	// The token is only embedded into links while query tokens are accepted.
+	query := url.Values{}
+	if h.allowQueryToken {
+		query.Set("token", p.token)
+	}
+
+	if p.testcase >= p.entry.Testcases {
+		io.WriteString(w, h.links.link(r, query, "assignment", p.mnr, "finish"))
+		return
+	}
+
+	nextLink := h.links.link(r, query, "assignment", p.mnr, "stage", p.stage, "testcase", strconv.Itoa(p.testcase+1))
+	io.WriteString(w, nextLink)
+}
+
+// postMove makes a move in a testcase of an interactive stage and answers
+// with what the student sees afterwards.
+func (h Handler) postMove(w http.ResponseWriter, r *http.Request) {
+	p := paramsFrom(r.Context())
+
+	mover, ok := p.entry.Runner.(stage.Mover)
+	if !ok {
+		writeProblem(w, r, http.StatusNotFound, codeNotInteractive, fmt.Sprintf("Stage %q does not take moves", p.stage))
+		return
+	}
+
+	defer r.Body.Close()
+	view, err := mover.Move(p.token, p.testcase, http.MaxBytesReader(w, r.Body, h.maxBodyBytes))
+
+	var schemaErr *stage.SchemaError
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		writeProblem(w, r, http.StatusRequestEntityTooLarge, codeSolutionTooLarge,
+			fmt.Sprintf("Moves may not exceed %d bytes", maxBytesErr.Limit))
+		return
+	case errors.As(err, &schemaErr):
+		writeProblemErrors(w, r, http.StatusBadRequest, codeMalformedMove,
+			"Move does not match the schema of the stage", schemaErr.Violations)
+		return
+	case errors.Is(err, stage.ErrMalformedMove):
+		writeProblem(w, r, http.StatusBadRequest, codeMalformedMove, err.Error())
+		return
+	case errors.Is(err, stage.ErrOutOfMoves):
+		writeProblem(w, r, http.StatusConflict, codeOutOfMoves, err.Error())
+		return
+	case err != nil:
+		log.Ctx(r.Context()).Err(err).Msg("Could not make move")
+		writeProblem(w, r, http.StatusInternalServerError, codeInternal, "Could not make move")
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, view)
+}
+
+// recordSubmission stores a submission in the history and updates the
+// progress of the student. Failing to store it does not fail the request.
+func (h Handler) recordSubmission(r *http.Request, p AssignmentParams, body []byte, correct bool, validateErr error) {
+	verdict := submission.Rejected
+	switch {
+	case validateErr != nil:
+		verdict = submission.Malformed
+	case correct:
+		verdict = submission.Accepted
+	}
+
+	now := time.Now()
+	var latency time.Duration
+	if fetchedAt, ok := h.progress.FetchedAt(p.mnr, p.stage, p.testcase); ok {
+		latency = now.Sub(fetchedAt)
+	}
+
+	if validateErr == nil {
+		h.progress.SolutionSubmitted(p.mnr, p.stage, p.testcase, correct)
+	}
+
+	sub := &submission.Submission{
+		Mnr:              p.mnr,
+		TokenFingerprint: token.Fingerprint(p.token),
+		Stage:            p.stage,
+		Testcase:         p.testcase,
+		Payload:          submission.PayloadFromBody(body),
+		Verdict:          verdict,
+		Latency:          latency,
+		SubmittedAt:      now,
+	}
+	err := h.submissions.Add(r.Context(), sub)
+	h.events.Publish(events.Event{
+		Type:       events.SolutionSubmitted,
+		Time:       now,
+		Mnr:        p.mnr,
+		Stage:      p.stage,
+		Testcase:   p.testcase,
+		Submission: sub.ID,
+		Verdict:    string(verdict),
+	})
+	if err != nil {
+		log.Ctx(r.Context()).Err(err).Msg("Could not store submission")
+		return
+	}
+
+	log.Ctx(r.Context()).Info().
+		Int64("submission", sub.ID).
+		Str("verdict", string(verdict)).
+		Dur("latency", latency).
+		Msg("Solution submitted")
+}
+
+// finishSummary is the answer of /finish once every testcase is solved.
+type finishSummary struct {
+	Mnr           string         `json:"mnr"`
+	FinishedAt    time.Time      `json:"finishedAt"`
+	Stages        []stageSummary `json:"stages"`
+	SolvedTotal   int            `json:"solvedTotal"`
+	AttemptsTotal int            `json:"attemptsTotal"`
+}
+
+type stageSummary struct {
+	ID        string `json:"id"`
+	Testcases int    `json:"testcases"`
+	Solved    int    `json:"solved"`
+	Attempts  int    `json:"attempts"`
+	// Missing lists the unsolved testcases.
+	Missing []int `json:"missing,omitempty"`
+}
+
+// getFinish records that the student finished the assignment, which
+// requires every testcase of every stage to be solved.
+func (h Handler) getFinish(w http.ResponseWriter, r *http.Request) {
+	mnr := paramsFrom(r.Context()).mnr
+
+	p := h.progress.Get(mnr)
+	var stages []stageSummary
+	var unfinished []stageSummary
+	for _, e := range h.stages.List() {
+		summary := stageSummary{ID: e.ID, Testcases: e.Testcases}
+		sp, started := p.Stages[e.ID]
+		solved := map[int]bool{}
+		if started {
+			summary.Attempts = sp.Attempts
+			for _, nr := range sp.Solved {
+				solved[nr] = true
+			}
+		}
+		for nr := 1; nr <= e.Testcases; nr++ {
+			if solved[nr] {
+				summary.Solved++
+			} else {
+				summary.Missing = append(summary.Missing, nr)
+			}
+		}
+		stages = append(stages, summary)
+		if len(summary.Missing) > 0 {
+			unfinished = append(unfinished, summary)
+		}
+	}
+
+	if len(unfinished) > 0 {
+		writeProblemErrors(w, r, http.StatusConflict, codeNotFinished,
+			fmt.Sprintf("%d stages have unsolved testcases", len(unfinished)), unfinished)
+		return
+	}
+
+	// Repeated calls only answer the summary again, so webhooks are sent
+	// once per student.
+	finishedAt, first := h.progress.Finished(mnr)
+	if first {
+		h.events.Publish(events.Event{Type: events.Finished, Mnr: mnr})
+	}
+
+	writeJSON(w, r, http.StatusOK, finishSummary{
+		Mnr:           mnr,
+		FinishedAt:    finishedAt,
+		Stages:        stages,
+		SolvedTotal:   p.SolvedTotal,
+		AttemptsTotal: p.AttemptsTotal,
+	})
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/Fancy11111/ase-prep/mock-api/config"
+	"github.com/Fancy11111/ase-prep/mock-api/events"
+	"github.com/Fancy11111/ase-prep/mock-api/roster"
+	"github.com/Fancy11111/ase-prep/mock-api/stage"
+	"github.com/Fancy11111/ase-prep/mock-api/submission"
+)
+
+const (
+	testMnr      = "12345678"
+	testMaxBytes = 1024
+)
+
+// newTestServer serves a single points-c stage with three generated
+// testcases and no curated ones to the students on the roster.
+func newTestServer(t *testing.T, students ...string) (*httptest.Server, Handler) {
+	t.Helper()
+
+	cfg := config.Default()
+	cfg.Stages = []config.StageConfig{{ID: "1", Kind: "points-c", Testcases: 3, EdgeCaseInterval: -1}}
+	cfg.Limits.MaxBodyBytes = testMaxBytes
+	cfg.Admin.Secret = "secret"
+
+	stages, err := createRegistry(cfg.Stages, "", stage.Options{Strict: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	links, err := newLinkBuilder(cfg.PublicURL, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := roster.New("", regexp.MustCompile(cfg.Students.MnrPattern))
+	if len(students) > 0 {
+		if err := list.Import(strings.NewReader(strings.Join(students, "\n"))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	handler := createHandler(cfg, stages, links, newLifecycle(), list, submission.NewMemoryStore())
+	mux := http.NewServeMux()
+	registerHandlers(mux, handler)
+	server := httptest.NewServer(newServerHandler(mux, handler))
+	t.Cleanup(server.Close)
+	return server, handler
+}
+
+func TestProblemStatus(t *testing.T) {
+	server, handler := newTestServer(t, testMnr, "87654321")
+	token, _, err := handler.tm.GetToken(testMnr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherToken, _, err := handler.tm.GetToken("87654321")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   string
+		status int
+		code   string
+	}{
+		{"invalid mnr", "GET", "/assignment/abc/token", "", "", http.StatusBadRequest, codeInvalidMnr},
+		{"not enrolled", "GET", "/assignment/11111111/token", "", "", http.StatusForbidden, codeUnknownStudent},
+		{"unknown stage", "GET", "/assignment/" + testMnr + "/stage/9/testcase/1", token, "", http.StatusNotFound, codeUnknownStage},
+		{"bad testcase number", "GET", "/assignment/" + testMnr + "/stage/1/testcase/one", token, "", http.StatusBadRequest, codeInvalidTestcaseNumber},
+		{"unknown testcase", "GET", "/assignment/" + testMnr + "/stage/1/testcase/4", token, "", http.StatusNotFound, codeUnknownTestcase},
+		{"missing token", "GET", "/assignment/" + testMnr + "/stage/1/testcase/1", "", "", http.StatusUnauthorized, codeInvalidToken},
+		{"wrong token", "GET", "/assignment/" + testMnr + "/stage/1/testcase/1", otherToken, "", http.StatusUnauthorized, codeInvalidToken},
+		{"malformed solution", "POST", "/assignment/" + testMnr + "/stage/1/testcase/1", token, "{", http.StatusBadRequest, codeMalformedSolution},
+		{"schema error", "POST", "/assignment/" + testMnr + "/stage/1/testcase/1", token, `{"accessiblePoints": [{"x": "1"}]}`, http.StatusBadRequest, codeMalformedSolution},
+		{"body too large", "POST", "/assignment/" + testMnr + "/stage/1/testcase/1", token, `{"accessiblePoints": [` + strings.Repeat(`{"x": 1, "y": 1},`, testMaxBytes/10) + `]}`, http.StatusRequestEntityTooLarge, codeSolutionTooLarge},
+		{"admin secret", "GET", "/admin/students", "wrong", "", http.StatusUnauthorized, codeInvalidAdminSecret},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, server.URL+tt.path, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if got := resp.Header.Get("Content-Type"); got != "application/problem+json" {
+				t.Errorf("Content-Type = %q, want application/problem+json", got)
+			}
+			var p problem
+			if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
+				t.Fatalf("decode problem: %v", err)
+			}
+			if p.Status != tt.status || p.Code != tt.code {
+				t.Errorf("problem = %d %q, want %d %q", p.Status, p.Code, tt.status, tt.code)
+			}
+		})
+	}
+}
+
+func TestSchemaErrorListsViolations(t *testing.T) {
+	server, handler := newTestServer(t)
+	token, _, err := handler.tm.GetToken(testMnr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := http.NewRequest("POST", server.URL+"/assignment/"+testMnr+"/stage/1/testcase/1",
+		strings.NewReader(`{"accessiblePoints": [{"x": "1", "y": 2}]}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var p struct {
+		Errors []stage.Violation `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Errors) != 1 || p.Errors[0].Field != "/accessiblePoints/0/x" {
+		t.Errorf("errors = %+v, want one violation of /accessiblePoints/0/x", p.Errors)
+	}
+}
+
+func TestHealthWhileShuttingDown(t *testing.T) {
+	server, handler := newTestServer(t)
+	handler.state.beginShutdown()
+
+	resp, err := http.Get(server.URL + "/health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	var p problem
+	if err := json.Unmarshal(body, &p); err != nil {
+		t.Fatalf("decode %q: %v", body, err)
+	}
+	if resp.StatusCode != http.StatusServiceUnavailable || p.Code != codeShuttingDown {
+		t.Errorf("GET /health = %d %q, want %d %q", resp.StatusCode, p.Code, http.StatusServiceUnavailable, codeShuttingDown)
+	}
+}
+
+func TestGetTokenOnlyCountsNewTokens(t *testing.T) {
+	server, handler := newTestServer(t)
+	sub := handler.events.Subscribe(events.Filter{Types: []events.Type{events.TokenIssued}}, 10)
+	defer sub.Close()
+
+	for range 3 {
+		resp, err := http.Get(server.URL + "/assignment/" + testMnr + "/token")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	if issued := handler.progress.Get(testMnr).TokensIssued; issued != 1 {
+		t.Errorf("TokensIssued = %d, want 1", issued)
+	}
+	if n := len(sub.Events()); n != 1 {
+		t.Errorf("published %d token-issued events, want 1", n)
+	}
+}
+
+func TestFinishRequiresAllTestcases(t *testing.T) {
+	server, handler := newTestServer(t)
+	token, _, err := handler.tm.GetToken(testMnr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	finish := func() *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest("GET", server.URL+"/assignment/"+testMnr+"/finish", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	handler.progress.SolutionSubmitted(testMnr, "1", 1, true)
+	resp := finish()
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("finish with unsolved testcases = %d, want %d", resp.StatusCode, http.StatusConflict)
+	}
+	if p := handler.progress.Get(testMnr); p.FinishedAt != nil {
+		t.Errorf("finish with unsolved testcases recorded a finish at %s", p.FinishedAt)
+	}
+
+	handler.progress.SolutionSubmitted(testMnr, "1", 2, true)
+	handler.progress.SolutionSubmitted(testMnr, "1", 3, true)
+	resp = finish()
+	defer resp.Body.Close()
+	var summary finishSummary
+	if err := json.NewDecoder(resp.Body).Decode(&summary); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || summary.SolvedTotal != 3 {
+		t.Errorf("finish = %d with %d solved, want 200 with 3 solved", resp.StatusCode, summary.SolvedTotal)
+	}
+	if p := handler.progress.Get(testMnr); p.FinishedAt == nil {
+		t.Error("finish was not recorded")
+	}
+}
+
+func TestProblemsAreLoggedPerHandler(t *testing.T) {
+	server, handler := newTestServer(t)
+
+	resp, err := http.Get(server.URL + "/assignment/abc/token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	problems := handler.problems.recent()
+	if len(problems) != 1 || problems[0].Code != codeInvalidMnr {
+		t.Errorf("recent problems = %+v, want one %s", problems, codeInvalidMnr)
+	}
+}
+
+func TestFinishPublishesOncePerStudent(t *testing.T) {
+	server, handler := newTestServer(t)
+	token, _, err := handler.tm.GetToken(testMnr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for nr := 1; nr <= 3; nr++ {
+		handler.progress.SolutionSubmitted(testMnr, "1", nr, true)
+	}
+	sub := handler.events.Subscribe(events.Filter{Types: []events.Type{events.Finished}}, 10)
+	defer sub.Close()
+
+	for range 3 {
+		req, _ := http.NewRequest("GET", server.URL+"/assignment/"+testMnr+"/finish", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("finish = %d, want 200", resp.StatusCode)
+		}
+	}
+
+	if n := len(sub.Events()); n != 1 {
+		t.Errorf("published %d finished events, want 1", n)
+	}
+}
+
+func TestGeneratorErrorIsAProblem(t *testing.T) {
+	server, handler := newTestServer(t)
+	fileStages, err := stage.LoadFileStages("stage/testdata/stages", stage.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range fileStages {
+		if err := handler.stages.Register(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	token, _, err := handler.tm.GetToken(testMnr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The generator of the flaky stage only works for the token tried when
+	// loading it.
+	req, _ := http.NewRequest("GET", server.URL+"/assignment/"+testMnr+"/stage/flaky/testcase/1", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var p problem
+	if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
+		t.Fatalf("decode problem: %v", err)
+	}
+	if resp.StatusCode != http.StatusInternalServerError || p.Code != codeInternal {
+		t.Errorf("answer = %d %q, want %d %q", resp.StatusCode, p.Code, http.StatusInternalServerError, codeInternal)
+	}
+}
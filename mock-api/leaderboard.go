@@ -0,0 +1,118 @@
+package main
+
+import (
+	"embed"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Fancy11111/ase-prep/mock-api/leaderboard"
+	"github.com/Fancy11111/ase-prep/mock-api/submission"
+	"github.com/rs/zerolog/log"
+)
+
+//go:embed templates
+var templates embed.FS
+
+var leaderboardTemplate = template.Must(template.ParseFS(templates, "templates/leaderboard.html"))
+
+type leaderboardPage struct {
+	GeneratedAt time.Time           `json:"generatedAt"`
+	Boards      []leaderboard.Board `json:"boards"`
+}
+
+// leaderboardOptions configure the public leaderboard.
+type leaderboardOptions struct {
+	public        bool
+	anonymization leaderboard.Anonymization
+	salt          string
+}
+
+// requireLeaderboardAccess lets everybody see the leaderboard if it is
+// public, otherwise only admins.
+func (h Handler) requireLeaderboardAccess(next http.Handler) http.Handler {
+	admin := h.requireAdmin(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.leaderboard.public {
+			next.ServeHTTP(w, r)
+			return
+		}
+		admin.ServeHTTP(w, r)
+	})
+}
+
+// getLeaderboard renders the leaderboard as JSON, or as HTML page if the
+// path ends in .html. Admins may override the anonymization with the
+// anonymize query parameter.
+func (h Handler) getLeaderboard(w http.ResponseWriter, r *http.Request) {
+	anonymization := h.leaderboard.anonymization
+	if v := r.URL.Query().Get("anonymize"); v != "" && strings.HasPrefix(r.URL.Path, "/admin/") {
+		parsed, err := leaderboard.ParseAnonymization(v)
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, codeInvalidFilter, err.Error())
+			return
+		}
+		anonymization = parsed
+	}
+
+	submissions, err := h.submissions.List(r.Context(), submission.Filter{})
+	if err != nil {
+		log.Ctx(r.Context()).Err(err).Msg("Could not list submissions")
+		writeProblem(w, r, http.StatusInternalServerError, codeInternal, "Could not compute leaderboard")
+		return
+	}
+
+	names := map[string]string{}
+	for _, s := range h.roster.Students() {
+		names[s.Mnr] = s.Name
+	}
+
+	var stages []leaderboard.StageInfo
+	for _, e := range h.stages.List() {
+		stages = append(stages, leaderboard.StageInfo{ID: e.ID, Testcases: e.Testcases})
+	}
+
+	page := leaderboardPage{
+		GeneratedAt: time.Now(),
+		Boards: leaderboard.Compute(stages, submissions, leaderboard.Options{
+			Anonymization: anonymization,
+			Salt:          h.leaderboard.salt,
+			Names:         names,
+			Timing:        h.timing,
+			Started:       h.stageStarted,
+		}),
+	}
+
+	if !strings.HasSuffix(r.URL.Path, ".html") {
+		writeJSON(w, r, http.StatusOK, page)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := leaderboardTemplate.Execute(w, page); err != nil {
+		log.Ctx(r.Context()).Err(err).Msg("Could not render leaderboard")
+	}
+}
+
+// timing looks up when a student got the first token and finished.
+func (h Handler) timing(mnr string) leaderboard.Timing {
+	var timing leaderboard.Timing
+	p := h.progress.Get(mnr)
+	if p.FirstTokenAt != nil {
+		timing.Start = *p.FirstTokenAt
+	}
+	if p.FinishedAt != nil {
+		timing.Finish = *p.FinishedAt
+	}
+	return timing
+}
+
+// stageStarted looks up when a student first fetched a testcase of stage.
+func (h Handler) stageStarted(mnr string, stage string) (time.Time, bool) {
+	sp, exists := h.progress.Get(mnr).Stages[stage]
+	if !exists || sp.StartedAt == nil {
+		return time.Time{}, false
+	}
+	return *sp.StartedAt, true
+}
@@ -0,0 +1,241 @@
+package leaderboard
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Fancy11111/ase-prep/mock-api/submission"
+)
+
+// Anonymization decides how students are named on the leaderboard.
+type Anonymization string
+
+const (
+	// ShowMnr shows the plain matriculation number.
+	ShowMnr Anonymization = "none"
+	// ShowName shows the name from the roster, falling back to a pseudonym.
+	ShowName Anonymization = "name"
+	// MaskMnr only shows the first and last two digits of the mnr.
+	MaskMnr Anonymization = "masked"
+	// Pseudonym shows a stable alias derived from the mnr.
+	Pseudonym Anonymization = "pseudonym"
+)
+
+func ParseAnonymization(s string) (Anonymization, error) {
+	switch a := Anonymization(strings.ToLower(s)); a {
+	case ShowMnr, ShowName, MaskMnr, Pseudonym:
+		return a, nil
+	}
+	return "", fmt.Errorf("unknown anonymization %q, use none, name, masked or pseudonym", s)
+}
+
+// Entry is the result of one student within a stage.
+type Entry struct {
+	Rank     int    `json:"rank"`
+	Student  string `json:"student"`
+	Solved   int    `json:"solved"`
+	Attempts int    `json:"attempts"`
+	// Duration is the total time from the first token to finishing the
+	// assignment, the same for every stage of a student.
+	Duration    time.Duration `json:"duration"`
+	DurationStr string        `json:"durationText"`
+	// StageDuration is the time from starting the stage to the last
+	// accepted submission to it.
+	StageDuration    time.Duration `json:"stageDuration"`
+	StageDurationStr string        `json:"stageDurationText"`
+	Finished         bool          `json:"finished"`
+}
+
+// Board is the ranking of one stage.
+type Board struct {
+	Stage     string  `json:"stage"`
+	Testcases int     `json:"testcases"`
+	Entries   []Entry `json:"entries"`
+}
+
+// Timing holds what is known about when a student started and finished,
+// independently of the submissions.
+type Timing struct {
+	Start  time.Time
+	Finish time.Time
+}
+
+// Options configure how a leaderboard is computed.
+type Options struct {
+	Anonymization Anonymization
+	// Salt keeps pseudonyms from being reversed by hashing all mnrs.
+	Salt string
+	// Names maps mnrs to the names of the roster.
+	Names map[string]string
+	// Timing returns start and finish of a student, zero values are
+	// replaced by the first submission respectively last accepted one.
+	Timing func(mnr string) Timing
+	// Started returns when a student started a stage, by default the
+	// first submission to it counts as start.
+	Started func(mnr string, stage string) (time.Time, bool)
+}
+
+type StageInfo struct {
+	ID        string
+	Testcases int
+}
+
+type result struct {
+	mnr       string
+	solved    map[int]bool
+	attempts  int
+	first     time.Time
+	lastSolve time.Time
+}
+
+// Compute ranks the students of every stage by solved testcases, then by the
+// total time from their first token to finishing, then by the time they spent
+// on the stage, then by their number of attempts.
+func Compute(stages []StageInfo, submissions []submission.Submission, opts Options) []Board {
+	results := map[string]map[string]*result{}
+	// totals has the first and last accepted submission of a student over
+	// all stages, the fallback if the timing is unknown.
+	totals := map[string]*result{}
+	for _, s := range submissions {
+		total, exists := totals[s.Mnr]
+		if !exists {
+			total = &result{mnr: s.Mnr}
+			totals[s.Mnr] = total
+		}
+		if total.first.IsZero() || s.SubmittedAt.Before(total.first) {
+			total.first = s.SubmittedAt
+		}
+		if s.Verdict == submission.Accepted && s.SubmittedAt.After(total.lastSolve) {
+			total.lastSolve = s.SubmittedAt
+		}
+
+		byMnr, exists := results[s.Stage]
+		if !exists {
+			byMnr = map[string]*result{}
+			results[s.Stage] = byMnr
+		}
+		res, exists := byMnr[s.Mnr]
+		if !exists {
+			res = &result{mnr: s.Mnr, solved: map[int]bool{}}
+			byMnr[s.Mnr] = res
+		}
+
+		res.attempts++
+		if res.first.IsZero() || s.SubmittedAt.Before(res.first) {
+			res.first = s.SubmittedAt
+		}
+		if s.Verdict == submission.Accepted {
+			res.solved[s.Testcase] = true
+			if s.SubmittedAt.After(res.lastSolve) {
+				res.lastSolve = s.SubmittedAt
+			}
+		}
+	}
+
+	boards := make([]Board, 0, len(stages))
+	for _, stage := range stages {
+		board := Board{Stage: stage.ID, Testcases: stage.Testcases, Entries: []Entry{}}
+		for _, res := range results[stage.ID] {
+			board.Entries = append(board.Entries, entry(res, totals[res.mnr], stage, opts))
+		}
+		rank(board.Entries)
+		boards = append(boards, board)
+	}
+	return boards
+}
+
+func entry(res *result, total *result, stage StageInfo, opts Options) Entry {
+	var timing Timing
+	if opts.Timing != nil {
+		timing = opts.Timing(res.mnr)
+	}
+	if timing.Start.IsZero() {
+		timing.Start = total.first
+	}
+	if timing.Finish.IsZero() {
+		timing.Finish = total.lastSolve
+	}
+
+	stageStart := res.first
+	if opts.Started != nil {
+		if started, ok := opts.Started(res.mnr, stage.ID); ok && started.Before(stageStart) {
+			stageStart = started
+		}
+	}
+
+	duration := between(timing.Start, timing.Finish)
+	stageDuration := between(stageStart, res.lastSolve)
+	return Entry{
+		Student:          displayName(res.mnr, opts),
+		Solved:           len(res.solved),
+		Attempts:         res.attempts,
+		Duration:         duration,
+		DurationStr:      duration.String(),
+		StageDuration:    stageDuration,
+		StageDurationStr: stageDuration.String(),
+		Finished:         len(res.solved) >= stage.Testcases,
+	}
+}
+
+// between returns the time from start to end, zero if end is unknown.
+func between(start, end time.Time) time.Duration {
+	if end.IsZero() || !end.After(start) {
+		return 0
+	}
+	return end.Sub(start).Round(time.Second)
+}
+
+func rank(entries []Entry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Solved != b.Solved {
+			return a.Solved > b.Solved
+		}
+		if a.Duration != b.Duration {
+			return a.Duration < b.Duration
+		}
+		if a.StageDuration != b.StageDuration {
+			return a.StageDuration < b.StageDuration
+		}
+		if a.Attempts != b.Attempts {
+			return a.Attempts < b.Attempts
+		}
+		return a.Student < b.Student
+	})
+
+	// Students with the same score share a rank.
+	for i := range entries {
+		entries[i].Rank = i + 1
+		if i > 0 && sameScore(entries[i-1], entries[i]) {
+			entries[i].Rank = entries[i-1].Rank
+		}
+	}
+}
+
+func sameScore(a, b Entry) bool {
+	return a.Solved == b.Solved && a.Duration == b.Duration &&
+		a.StageDuration == b.StageDuration && a.Attempts == b.Attempts
+}
+
+func displayName(mnr string, opts Options) string {
+	switch opts.Anonymization {
+	case ShowMnr:
+		return mnr
+	case ShowName:
+		if name := opts.Names[mnr]; name != "" {
+			return name
+		}
+	case MaskMnr:
+		if len(mnr) <= 4 {
+			return strings.Repeat("*", len(mnr))
+		}
+		return mnr[:2] + strings.Repeat("*", len(mnr)-4) + mnr[len(mnr)-2:]
+	}
+
+	sum := sha256.Sum256([]byte(opts.Salt + ":" + mnr))
+	return "Student " + hex.EncodeToString(sum[:])[:6]
+}
@@ -0,0 +1,88 @@
+package leaderboard
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Fancy11111/ase-prep/mock-api/submission"
+)
+
+func TestComputeRanksByTotalTime(t *testing.T) {
+	start := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time {
+		return start.Add(time.Duration(minutes) * time.Minute)
+	}
+	submissions := []submission.Submission{
+		// fast got the first token late, solved the stage within 5
+		// minutes and finished 10 minutes after the first token.
+		{Mnr: "fast", Stage: "1", Testcase: 1, Verdict: submission.Accepted, SubmittedAt: at(62)},
+		{Mnr: "fast", Stage: "1", Testcase: 2, Verdict: submission.Accepted, SubmittedAt: at(65)},
+		// slow solved the stage within 3 minutes but needed 20 in total.
+		{Mnr: "slow", Stage: "1", Testcase: 1, Verdict: submission.Accepted, SubmittedAt: at(18)},
+		{Mnr: "slow", Stage: "1", Testcase: 2, Verdict: submission.Accepted, SubmittedAt: at(20)},
+		// partial only solved one testcase, which does not count as quick.
+		{Mnr: "partial", Stage: "1", Testcase: 1, Verdict: submission.Accepted, SubmittedAt: at(1)},
+		{Mnr: "partial", Stage: "1", Testcase: 2, Verdict: submission.Rejected, SubmittedAt: at(2)},
+	}
+	timings := map[string]Timing{
+		"fast": {Start: at(60), Finish: at(70)},
+		"slow": {Start: at(0), Finish: at(20)},
+		// partial did not finish, the last accepted submission counts.
+		"partial": {Start: at(0)},
+	}
+	started := map[string]time.Time{"fast": at(60), "slow": at(17), "partial": at(0)}
+
+	boards := Compute([]StageInfo{{ID: "1", Testcases: 2}}, submissions, Options{
+		Anonymization: ShowMnr,
+		Timing: func(mnr string) Timing {
+			return timings[mnr]
+		},
+		Started: func(mnr string, stage string) (time.Time, bool) {
+			s, ok := started[mnr]
+			return s, ok
+		},
+	})
+
+	want := []struct {
+		student       string
+		duration      time.Duration
+		stageDuration time.Duration
+	}{
+		{"fast", 10 * time.Minute, 5 * time.Minute},
+		{"slow", 20 * time.Minute, 3 * time.Minute},
+		{"partial", time.Minute, time.Minute},
+	}
+	entries := boards[0].Entries
+	if len(entries) != len(want) {
+		t.Fatalf("got %d entries, want %d", len(entries), len(want))
+	}
+	for i, w := range want {
+		e := entries[i]
+		if e.Student != w.student || e.Duration != w.duration || e.StageDuration != w.stageDuration || e.Rank != i+1 {
+			t.Errorf("entry %d = %s (%s, stage %s, rank %d), want %s (%s, stage %s, rank %d)",
+				i, e.Student, e.Duration, e.StageDuration, e.Rank, w.student, w.duration, w.stageDuration, i+1)
+		}
+	}
+}
+
+func TestComputeFallsBackToSubmissions(t *testing.T) {
+	start := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	submissions := []submission.Submission{
+		{Mnr: "a", Stage: "1", Testcase: 1, Verdict: submission.Accepted, SubmittedAt: start},
+		{Mnr: "a", Stage: "2", Testcase: 1, Verdict: submission.Accepted, SubmittedAt: start.Add(4 * time.Minute)},
+		{Mnr: "a", Stage: "2", Testcase: 2, Verdict: submission.Rejected, SubmittedAt: start.Add(9 * time.Minute)},
+	}
+
+	boards := Compute([]StageInfo{{ID: "1", Testcases: 1}, {ID: "2", Testcases: 2}}, submissions, Options{Anonymization: ShowMnr})
+
+	// Without timing the total spans from the first submission to the last
+	// accepted one of any stage.
+	for _, board := range boards {
+		if e := board.Entries[0]; e.Duration != 4*time.Minute {
+			t.Errorf("stage %s: total time %s, want 4m0s", board.Stage, e.Duration)
+		}
+	}
+	if e := boards[1].Entries[0]; e.StageDuration != 0 {
+		t.Errorf("stage 2: stage time %s, want 0s for a single accepted submission", e.StageDuration)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const readinessCheckTimeout = 2 * time.Second
+
+// lifecycle tracks the state of the server process as seen by health checks.
+type lifecycle struct {
+	shuttingDown atomic.Bool
+	stopping     chan struct{}
+	stopOnce     sync.Once
+
+	mu     sync.RWMutex
+	checks []readinessCheck
+}
+
+type readinessCheck struct {
+	name  string
+	check func(context.Context) error
+}
+
+type checkResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type readinessReport struct {
+	Status string                 `json:"status"`
+	Checks map[string]checkResult `json:"checks"`
+}
+
+func newLifecycle() *lifecycle {
+	return &lifecycle{stopping: make(chan struct{})}
+}
+
+func (l *lifecycle) beginShutdown() {
+	l.shuttingDown.Store(true)
+	l.stopOnce.Do(func() { close(l.stopping) })
+}
+
+// done is closed once the shutdown begins. Long running responses like
+// event streams end on it, as server.Shutdown waits for them.
+func (l *lifecycle) done() <-chan struct{} {
+	return l.stopping
+}
+
+func (l *lifecycle) isShuttingDown() bool {
+	return l.shuttingDown.Load()
+}
+
+// addReadinessCheck registers a dependency that has to be usable for the
+// server to accept traffic.
+func (l *lifecycle) addReadinessCheck(name string, check func(context.Context) error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.checks = append(l.checks, readinessCheck{name: name, check: check})
+}
+
+// readiness runs all checks and reports whether the server is ready.
+func (l *lifecycle) readiness(ctx context.Context) (bool, readinessReport) {
+	l.mu.RLock()
+	checks := append([]readinessCheck{{name: "shutdown", check: l.checkShutdown}}, l.checks...)
+	l.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, readinessCheckTimeout)
+	defer cancel()
+
+	ready := true
+	report := readinessReport{Status: "ready", Checks: map[string]checkResult{}}
+	for _, c := range checks {
+		if err := c.check(ctx); err != nil {
+			ready = false
+			report.Checks[c.name] = checkResult{Status: "failing", Error: err.Error()}
+			continue
+		}
+		report.Checks[c.name] = checkResult{Status: "ok"}
+	}
+
+	if !ready {
+		report.Status = "not ready"
+	}
+	return ready, report
+}
+
+func (l *lifecycle) checkShutdown(context.Context) error {
+	if l.isShuttingDown() {
+		return errors.New("server is shutting down")
+	}
+	return nil
+}
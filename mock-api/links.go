@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// linkBuilder creates the absolute links handed out in responses.
+type linkBuilder struct {
+	base *url.URL
+	// trustForwarded makes X-Forwarded-Proto and X-Forwarded-Host override
+	// the configured base URL, only enable it behind a trusted proxy.
+	trustForwarded bool
+}
+
+func newLinkBuilder(publicURL string, trustForwarded bool) (linkBuilder, error) {
+	base, err := url.Parse(publicURL)
+	if err != nil {
+		return linkBuilder{}, err
+	}
+	return linkBuilder{base: base, trustForwarded: trustForwarded}, nil
+}
+
+func (lb linkBuilder) baseFor(r *http.Request) *url.URL {
+	base := *lb.base
+	if !lb.trustForwarded {
+		return &base
+	}
+
+	if proto := firstHeaderValue(r, "X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		base.Scheme = proto
+	}
+	if host := firstHeaderValue(r, "X-Forwarded-Host"); host != "" {
+		base.Host = host
+	}
+	return &base
+}
+
+// link joins the path segments onto the base URL of the request, segments
+// are escaped so user supplied values can not change the structure of the link.
+func (lb linkBuilder) link(r *http.Request, query url.Values, segments ...string) string {
+	escaped := make([]string, len(segments))
+	for i, segment := range segments {
+		// PathEscape leaves dots alone, which JoinPath would resolve.
+		if segment == "." || segment == ".." {
+			segment = strings.ReplaceAll(segment, ".", "%2E")
+		} else {
+			segment = url.PathEscape(segment)
+		}
+		escaped[i] = segment
+	}
+	u := lb.baseFor(r).JoinPath(escaped...)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// firstHeaderValue returns the first entry of a possibly comma separated
+// header, as appended by chained proxies.
+func firstHeaderValue(r *http.Request, name string) string {
+	value, _, _ := strings.Cut(r.Header.Get(name), ",")
+	return strings.TrimSpace(value)
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLinkEscapesSegments(t *testing.T) {
+	links, err := newLinkBuilder("https://mock.example/api", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest("GET", "/", nil)
+
+	tests := []struct {
+		segments []string
+		want     string
+	}{
+		{[]string{"assignment", "12345678", "finish"}, "https://mock.example/api/assignment/12345678/finish"},
+		{[]string{"stage", "a/b", "testcase"}, "https://mock.example/api/stage/a%2Fb/testcase"},
+		{[]string{"stage", "a b?c#d"}, "https://mock.example/api/stage/a%20b%3Fc%23d"},
+		{[]string{"stage", "..", "finish"}, "https://mock.example/api/stage/%2E%2E/finish"},
+	}
+	for _, tt := range tests {
+		if got := links.link(r, nil, tt.segments...); got != tt.want {
+			t.Errorf("link(%q) = %s, want %s", tt.segments, got, tt.want)
+		}
+	}
+}
@@ -1,20 +1,28 @@
 package main
 
 import (
+	"cmp"
 	"context"
-	"encoding/json"
-	"fmt"
+	"errors"
+	"flag"
+	"github.com/Fancy11111/ase-prep/mock-api/config"
+	"github.com/Fancy11111/ase-prep/mock-api/events"
+	"github.com/Fancy11111/ase-prep/mock-api/leaderboard"
+	"github.com/Fancy11111/ase-prep/mock-api/progress"
+	"github.com/Fancy11111/ase-prep/mock-api/roster"
 	"github.com/Fancy11111/ase-prep/mock-api/stage"
+	"github.com/Fancy11111/ase-prep/mock-api/submission"
 	"github.com/Fancy11111/ase-prep/mock-api/token"
+	"github.com/Fancy11111/ase-prep/mock-api/webhook"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
-	"io"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
-	"strconv"
+	"regexp"
 	"syscall"
+	"time"
 
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
@@ -27,49 +35,124 @@ func main() {
 
 func run() (err error) {
 	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
-	mux := http.NewServeMux()
-	registerHandlers(mux)
 
-	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
-	defer stop()
+	cfg, err := config.Load(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	if err != nil {
+		return
+	}
 
-	addr := os.Getenv("ADDR")
-	fmt.Println(addr)
+	if cfg.PrintConfig {
+		return cfg.Print(os.Stdout)
+	}
 
-	if addr == "" {
-		addr = "localhost"
+	// A redaction of none logs tokens in full, only use it for local debugging.
+	redactionMode, err := token.ParseRedactionMode(cfg.Token.Redaction)
+	if err != nil {
+		return
+	}
+	token.SetRedactionMode(redactionMode)
+
+	// A token expires at most Token.TTL after any request made with it, so
+	// state of interactive stages unused for that long is of no use anymore.
+	stageOpts := stage.Options{
+		Strict:   cfg.Submissions.Strict,
+		CasesDir: cfg.CasesDir,
+		StateTTL: cfg.Token.TTL,
+	}
+	stages, err := createRegistry(cfg.Stages, cfg.StageDir, stageOpts)
+	if err != nil {
+		return
 	}
 
-	port := os.Getenv("PORT")
-	fmt.Println(port)
+	links, err := newLinkBuilder(cfg.PublicURL, cfg.TrustForwardedHeaders)
+	if err != nil {
+		return
+	}
 
-	if port == "" {
-		port = "3000"
+	students := roster.New(cfg.Students.Roster, regexp.MustCompile(cfg.Students.MnrPattern))
+	if cfg.Students.Roster != "" {
+		if err = students.Reload(); err != nil {
+			return
+		}
+		log.Info().Int("students", students.Len()).Str("roster", cfg.Students.Roster).Msg("Loaded roster")
 	}
 
-	baseAddr := fmt.Sprintf("%s:%s", addr, port)
-	ctx = context.WithValue(ctx, "baseAddr", baseAddr)
+	submissions, err := submission.Open(cfg.Submissions.Store, cfg.Submissions.Path)
+	if err != nil {
+		return
+	}
+	// Closed after the server has been drained, see shutdown.
+	defer func() {
+		err = errors.Join(err, submissions.Close())
+	}()
 
-	/*	otelShutdown, err := setupOTelSDK(ctx)
-		if err != nil {
+	state := newLifecycle()
+	handler := createHandler(cfg, stages, links, state, students, submissions)
+	state.addReadinessCheck("tokenStore", handler.tm.Ping)
+	state.addReadinessCheck("stages", stages.Ping)
+	state.addReadinessCheck("submissionStore", submissions.Ping)
+
+	mux := http.NewServeMux()
+	registerHandlers(mux, handler)
+
+	if len(cfg.Webhooks.Endpoints) > 0 {
+		stopWebhooks := startWebhooks(cfg.Webhooks, handler.events)
+		// Stopped after the server has been drained, so the finish events
+		// of in-flight requests are still delivered.
+		defer stopWebhooks()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if cfg.Students.Roster != "" {
+		go reloadRosterOnSIGHUP(ctx, students)
+	}
+
+	if cfg.StageDir != "" {
+		// Tokens and progress are kept by the handler, so students keep
+		// going with the reloaded stages.
+		if err = stage.WatchFileStages(ctx, cfg.StageDir, stageOpts, stages); err != nil {
 			return
 		}
+	}
+
+	if cfg.Telemetry.Enabled {
+		otelShutdown, otelErr := setupOTelSDK(ctx)
+		if otelErr != nil {
+			return otelErr
+		}
 		// Handle shutdown properly so nothing leaks.
+		// Deferred calls run after the server has been drained.
 		defer func() {
 			err = errors.Join(err, otelShutdown(context.Background()))
-		}()*/
+		}()
+	}
+
+	// Requests must not inherit the signal context, otherwise in-flight
+	// requests would be cancelled as soon as the shutdown starts.
+	baseCtx, cancelBase := context.WithCancel(context.Background())
+	defer cancelBase()
 
 	server := &http.Server{
-		Addr:    fmt.Sprintf(":%s", port),
-		Handler: mux,
+		Addr:              cfg.Listen,
+		Handler:           newServerHandler(mux, handler),
+		ReadHeaderTimeout: cfg.Limits.ReadHeaderTimeout,
 		BaseContext: func(net.Listener) context.Context {
-			return ctx
+			return baseCtx
 		},
 	}
 
 	srvErr := make(chan error, 1)
 	go func() {
-		log.Info().Str("baseAddress", baseAddr).Msg("Starting server")
+		log.Info().
+			Str("listen", cfg.Listen).
+			Str("publicUrl", cfg.PublicURL).
+			Stringer("tokenRedaction", redactionMode).
+			Msg("Starting server")
 		srvErr <- server.ListenAndServe()
 	}()
 
@@ -83,230 +166,178 @@ func run() (err error) {
 		stop()
 	}
 
-	/*go func() {
-		signalHandler := make(chan os.Signal, 1)
-		signal.Notify(signalHandler, syscall.SIGINT, syscall.SIGTERM)
-
-		signal := <-signalHandler
-		log.Info().Any("signal", signal).Msg("Got signal, shutting down")
-
-		server.Close()
-	}()*/
-
-	/*if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
-		log.Err(err).Msg("Error while running server")
-	} else {
-		log.Info().Msg("Server shut down gracefully")
-	}*/
-
-	//cancelCtx()
-	return
+	return shutdown(server, state, cfg.ShutdownDelay, cfg.ShutdownTimeout)
 }
 
-func createHandler(stage stage.StagePointsC) Handler {
-	return Handler{
-		tm:    token.NewTokenManagerInMemory(),
-		stage: stage,
+// shutdown marks the server as not ready, keeps serving for delay so probes
+// notice, and then waits up to timeout for in-flight requests to complete,
+// before forcefully closing connections.
+func shutdown(server *http.Server, state *lifecycle, delay, timeout time.Duration) error {
+	state.beginShutdown()
+	if delay > 0 {
+		log.Info().Dur("delay", delay).Msg("Shutting down, reporting not ready")
+		time.Sleep(delay)
 	}
-}
-
-func registerHandlers(mux *http.ServeMux) {
+	log.Info().Dur("timeout", timeout).Msg("Shutting down, draining in-flight requests")
 
-	handler := createHandler(stage.NewStagePointC())
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
 
-	handleFunc := func(pattern string, handlerFunc func(http.ResponseWriter, *http.Request)) {
-		// Configure the "http.route" for the HTTP instrumentation.
-		h := otelhttp.WithRouteTag(pattern, http.HandlerFunc(handlerFunc))
-		mux.Handle(pattern, h)
+	if err := server.Shutdown(ctx); err != nil {
+		log.Err(err).Msg("Could not drain in-flight requests in time, closing connections")
+		return errors.Join(err, server.Close())
 	}
 
-	handleFunc("GET /ping", func(w http.ResponseWriter, r *http.Request) {
-		log.Info().Any("url", r.URL.Path).Any("method", r.Method).Msg("")
-		w.WriteHeader(http.StatusOK)
-
-		io.WriteString(w, "pong!")
-	})
-
-	handleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
-		log.Info().Any("url", r.URL.Path).Any("method", r.Method).Msg("")
-		w.WriteHeader(http.StatusOK)
-
-		io.WriteString(w, "healthy")
-	})
+	log.Info().Msg("Server shut down gracefully")
+	return nil
+}
 
-	handleFunc("GET /assignment/{mnr}/token", func(w http.ResponseWriter, r *http.Request) {
-		mnr := r.PathValue("mnr")
-		log.Info().Any("url", r.URL.Path).Any("method", r.Method).Str("mnr", mnr).Msg("")
-		handler.getToken(w, r, mnr)
+// startWebhooks delivers the events of bus to the configured endpoints until
+// the returned function is called, which waits for pending deliveries.
+func startWebhooks(cfg config.WebhooksConfig, bus *events.Bus) func() {
+	endpoints := make([]webhook.Endpoint, len(cfg.Endpoints))
+	for i, e := range cfg.Endpoints {
+		endpoints[i] = webhook.Endpoint{URL: e.URL, Secret: e.Secret, Accepted: e.Accepted}
+	}
+	dispatcher := webhook.New(endpoints, webhook.Options{
+		MaxAttempts: cfg.MaxAttempts,
+		Backoff:     cfg.Backoff,
+		Timeout:     cfg.Timeout,
+		DeadLetter:  cfg.DeadLetter,
 	})
 
-	handleFunc("GET /assignment/{mnr}/token/reset", func(w http.ResponseWriter, r *http.Request) {
-		mnr := r.PathValue("mnr")
-		log.Info().Any("url", r.URL.Path).Any("method", r.Method).Str("mnr", mnr).Msg("")
-		handler.tm.ResetToken(mnr)
-		w.WriteHeader(http.StatusOK)
-	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := dispatcher.Start(ctx, bus)
+	log.Info().Int("endpoints", len(endpoints)).Msg("Sending webhooks")
 
-	handleFunc("GET /assignment/{mnr}/stage/{stage}/testcase/{testcase}", func(w http.ResponseWriter, r *http.Request) {
-		mnr := r.PathValue("mnr")
-		stageNr := r.PathValue("stage")
-		testcase := r.PathValue("testcase")
-		token := r.URL.Query().Get("token")
+	return func() {
+		cancel()
+		<-done
+	}
+}
 
-		testcaseNr, err := strconv.Atoi(testcase)
+// createRegistry registers the configured stages followed by the file
+// stages of stageDir, if set.
+func createRegistry(stages []config.StageConfig, stageDir string, opts stage.Options) (*stage.Registry, error) {
+	var entries []stage.Entry
+	for _, s := range stages {
+		stageOpts := opts
+		stageOpts.EdgeCaseInterval = s.EdgeCaseInterval
+		runner, err := stage.New(s.Kind, stageOpts)
 		if err != nil {
-			log.Err(err).Str("testcase", testcase).Msg("Could not parse testcase number")
-			w.WriteHeader(http.StatusBadRequest)
-			io.WriteString(w, "Could not parse testcase number")
-			return
+			return nil, err
 		}
-
-		log.Info().Any("url", r.URL.Path).
-			Any("method", r.Method).
-			Str("mnr", mnr).
-			Str("stage", stageNr).
-			Str("testcase", testcase).
-			Str("token", token).
-			Msg("")
-
-		handler.getTestcase(w, r, TestcaseInfo{
-			mnr:      mnr,
-			stage:    stageNr,
-			testcase: testcaseNr,
-			token:    token,
+		entries = append(entries, stage.Entry{
+			ID:        s.ID,
+			Kind:      s.Kind,
+			Testcases: runner.Testcases(s.Testcases),
+			Runner:    runner,
 		})
-	})
-
-	handleFunc("POST /assignment/{mnr}/stage/{stage}/testcase/{testcase}", func(w http.ResponseWriter, r *http.Request) {
-		mnr := r.PathValue("mnr")
-		stageNr := r.PathValue("stage")
-		testcase := r.PathValue("testcase")
-		token := r.URL.Query().Get("token")
+	}
 
-		testcaseNr, err := strconv.Atoi(testcase)
+	if stageDir != "" {
+		fileStages, err := stage.LoadFileStages(stageDir, opts)
 		if err != nil {
-			log.Err(err).Str("testcase", testcase).Msg("Could not parse testcase number")
-			w.WriteHeader(http.StatusBadRequest)
-			io.WriteString(w, "Could not parse testcase number")
-			return
+			return nil, err
 		}
-
-		log.Info().Any("url", r.URL.Path).
-			Any("method", r.Method).
-			Str("mnr", mnr).
-			Str("stage", stageNr).
-			Str("testcase", testcase).
-			Str("token", token).
-			Msg("")
-
-		handler.postTestResult(w, r, TestcaseInfo{
-			mnr:      mnr,
-			stage:    stageNr,
-			testcase: testcaseNr,
-			token:    token,
-		})
-	})
-
-	handleFunc("GET /assignment/{mnr}/finish", func(w http.ResponseWriter, r *http.Request) {
-		mnr := r.PathValue("mnr")
-		token := r.URL.Query().Get("token")
-
-		log.Info().Any("url", r.URL.Path).
-			Any("method", r.Method).
-			Str("mnr", mnr).
-			Str("token", token).
-			Msg("")
-
-		handler.getFinish(w, r)
-	})
-	// mux.HandleFunc("")
-}
-
-type Handler struct {
-	tm    token.TokenManager
-	stage stage.Stage[stage.TestCase, stage.Solution]
-}
-
-func (h Handler) getToken(w http.ResponseWriter, r *http.Request, mnr string) {
-	w.WriteHeader(http.StatusOK)
-
-	token, err := h.tm.GetToken(mnr)
-	if err == nil {
-		io.WriteString(w, token)
+		log.Info().Int("stages", len(fileStages)).Str("stageDir", stageDir).Msg("Loaded file stages")
+		entries = append(entries, fileStages...)
 	}
-}
-
-type TestcaseInfo struct {
-	mnr      string
-	stage    string
-	testcase int
-	token    string
-}
-
-func (h Handler) getTestcase(w http.ResponseWriter, r *http.Request, ti TestcaseInfo) {
 
-	valid, err := h.tm.ValidateToken(ti.mnr, ti.token)
-
-	if !valid {
-		io.WriteString(w, fmt.Sprintf("%v", err))
-		w.WriteHeader(http.StatusUnauthorized)
-		return
-	}
-
-	testcase := h.stage.CreateTestcase(ti.token, ti.testcase)
-
-	log.Debug().Any("testcase", testcase).Msg("")
-
-	encoded, err := json.Marshal(testcase)
-	if err != nil {
-		log.Err(err).Msg("Could not marshal testcase")
+	registry := stage.NewRegistry()
+	for _, e := range entries {
+		if err := registry.Register(e); err != nil {
+			return nil, err
+		}
 	}
-
-	log.Debug().Str("encoded", string(encoded)).Msg("encoded testcase")
-
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(encoded)
+	return registry, nil
 }
 
-func (h Handler) postTestResult(w http.ResponseWriter, r *http.Request, ti TestcaseInfo) {
-	log.Info().Any("url", r.URL.Path).Any("method", r.Method).Msg("")
-
-	valid, err := h.tm.ValidateToken(ti.mnr, ti.token)
-
-	if !valid {
-		io.WriteString(w, fmt.Sprintf("%v", err))
-		w.WriteHeader(http.StatusUnauthorized)
-		return
+func createHandler(cfg config.Config, stages *stage.Registry, links linkBuilder, state *lifecycle, students *roster.Roster, submissions submission.Store) Handler {
+	return Handler{
+		mnrPattern:      regexp.MustCompile(cfg.Students.MnrPattern),
+		roster:          students,
+		progress:        progress.NewTracker(),
+		submissions:     submissions,
+		events:          events.NewBus(),
+		problems:        newProblemLog(recentProblemsSize),
+		adminSecret:     cfg.Admin.Secret,
+		state:           state,
+		tm:              token.NewTokenManagerInMemory(cfg.Token.TTL),
+		stages:          stages,
+		links:           links,
+		allowQueryToken: cfg.Token.AllowQuery,
+		maxBodyBytes:    cfg.Limits.MaxBodyBytes,
+		leaderboard: leaderboardOptions{
+			public:        cfg.Leaderboard.Public,
+			anonymization: leaderboard.Anonymization(cfg.Leaderboard.Anonymization),
+			salt:          cmp.Or(cfg.Leaderboard.Salt, newRequestID()),
+		},
 	}
+}
 
-	defer r.Body.Close()
-	solution := stage.Solution{}
-	err = json.NewDecoder(r.Body).Decode(&solution)
+func registerHandlers(mux *http.ServeMux, handler Handler) {
 
-	if err != nil {
-		log.Err(err).Msg("Could not unmarshal solution")
-		w.WriteHeader(http.StatusBadRequest)
-		io.WriteString(w, "Could not parse solution")
-		return
+	handle := func(pattern string, handlerFunc http.HandlerFunc, middlewares ...middleware) {
+		// Configure the "http.route" for the HTTP instrumentation.
+		h := otelhttp.WithRouteTag(pattern, chain(handlerFunc, middlewares...))
+		mux.Handle(pattern, h)
 	}
 
-	h.stage.ValidateSolution(ti.token, ti.testcase, solution)
-
-	w.WriteHeader(http.StatusOK)
+	assignment := []middleware{handler.withAssignmentParams}
+	authenticated := []middleware{handler.withAssignmentParams, handler.requireToken}
+
+	handle("GET /ping", handler.ping)
+	handle("GET /health", handler.health)
+	handle("GET /livez", handler.livez)
+	handle("GET /readyz", handler.readyz)
+
+	handle("GET /assignment/{mnr}/token", handler.getToken, assignment...)
+	handle("GET /assignment/{mnr}/token/reset", handler.resetToken, assignment...)
+	handle("GET /assignment/{mnr}/stage/{stage}", handler.getStage, assignment...)
+	handle("GET /assignment/{mnr}/stage/{stage}/testcase/{testcase}", handler.getTestcase, authenticated...)
+	handle("POST /assignment/{mnr}/stage/{stage}/testcase/{testcase}", handler.postTestResult, authenticated...)
+	handle("POST /assignment/{mnr}/stage/{stage}/testcase/{testcase}/move", handler.postMove, authenticated...)
+	handle("GET /assignment/{mnr}/finish", handler.getFinish, authenticated...)
+
+	handle("GET /leaderboard", handler.getLeaderboard, handler.requireLeaderboardAccess)
+	handle("GET /leaderboard.html", handler.getLeaderboard, handler.requireLeaderboardAccess)
+
+	handle("GET /admin/leaderboard", handler.getLeaderboard, handler.requireAdmin)
+	handle("GET /admin/leaderboard.html", handler.getLeaderboard, handler.requireAdmin)
+	handle("GET /admin/dashboard", handler.getDashboard, handler.requireAdmin)
+	handle("GET /admin/dashboard/events", handler.dashboardEvents, handler.requireAdmin)
+	handle("GET /admin/events", handler.streamEvents, handler.requireAdmin)
+	handle("POST /admin/roster/reload", handler.reloadRoster, handler.requireAdmin)
+	handle("PUT /admin/roster", handler.importRoster, handler.requireAdmin)
+	handle("GET /admin/students", handler.listStudents, handler.requireAdmin)
+	handle("GET /admin/students/{mnr}", handler.getStudent, handler.requireAdmin)
+	handle("GET /admin/submissions", handler.listSubmissions, handler.requireAdmin)
+	handle("GET /admin/submissions/{id}", handler.getSubmission, handler.requireAdmin)
+}
 
-	baseUrl := r.Context().Value("baseAddr")
+// reloadRosterOnSIGHUP reloads the roster whenever the process receives
+// SIGHUP, until ctx is done.
+func reloadRosterOnSIGHUP(ctx context.Context, students *roster.Roster) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
 
-	if ti.testcase >= 10 {
-		io.WriteString(w, fmt.Sprintf("%s/assignment/%s/finish?token=%s", baseUrl, ti.mnr, ti.token))
-		return
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			if err := students.Reload(); err != nil {
+				log.Err(err).Msg("Could not reload roster, keeping the previous one")
+				continue
+			}
+			log.Info().Int("students", students.Len()).Msg("Reloaded roster")
+		}
 	}
-
-	nextLink := fmt.Sprintf("%s/assignment/%s/stage/%s/testcase/%d?token=%s", baseUrl, ti.mnr, ti.stage, ti.testcase+1, ti.token)
-	io.WriteString(w, nextLink)
 }
 
-func (h Handler) getFinish(w http.ResponseWriter, r *http.Request) {
-	log.Info().Any("url", r.URL.Path).Any("method", r.Method).Msg("")
-	w.WriteHeader(http.StatusOK)
-	io.WriteString(w, "TODO")
+// newServerHandler wraps the mux in the middlewares every request passes.
+func newServerHandler(mux *http.ServeMux, handler Handler) http.Handler {
+	return chain(mux, withRequestID, withAccessLog, handler.withProblemLog, withRecovery)
 }
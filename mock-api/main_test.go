@@ -0,0 +1,111 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestShutdownDrainsInFlightRequests(t *testing.T) {
+	state := newLifecycle()
+	handler := Handler{state: state}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		io.WriteString(w, "done")
+	})
+	mux.HandleFunc("GET /readyz", handler.readyz)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{Handler: mux}
+	go server.Serve(listener)
+	baseURL := "http://" + listener.Addr().String()
+
+	type result struct {
+		status int
+		body   string
+		err    error
+	}
+	slow := make(chan result, 1)
+	go func() {
+		resp, err := http.Get(baseURL + "/slow")
+		if err != nil {
+			slow <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		slow <- result{status: resp.StatusCode, body: string(body), err: err}
+	}()
+	<-started
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- shutdown(server, state, 500*time.Millisecond, 5*time.Second)
+	}()
+	for !state.isShuttingDown() {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The listener stays open for the delay, so probes see the server
+	// is no longer ready.
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	resp, err := client.Get(baseURL + "/readyz")
+	if err != nil {
+		t.Fatalf("GET /readyz during shutdown: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("GET /readyz during shutdown = %d, want %d", resp.StatusCode, http.StatusServiceUnavailable)
+	}
+
+	select {
+	case err := <-shutdownErr:
+		t.Fatalf("shutdown returned before the in-flight request completed: %v", err)
+	case <-time.After(700 * time.Millisecond):
+	}
+
+	close(release)
+	if r := <-slow; r.err != nil || r.status != http.StatusOK || r.body != "done" {
+		t.Errorf("in-flight request = %d %q, %v, want 200 \"done\"", r.status, r.body, r.err)
+	}
+	if err := <-shutdownErr; err != nil {
+		t.Errorf("shutdown: %v", err)
+	}
+}
+
+func TestShutdownClosesConnectionsAfterTimeout(t *testing.T) {
+	state := newLifecycle()
+	release := make(chan struct{})
+	defer close(release)
+
+	started := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{Handler: mux}
+	go server.Serve(listener)
+
+	go http.Get("http://" + listener.Addr().String() + "/slow")
+	<-started
+
+	if err := shutdown(server, state, 0, 100*time.Millisecond); err == nil {
+		t.Error("shutdown did not report the request still running after the timeout")
+	}
+}
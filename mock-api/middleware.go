@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	"github.com/Fancy11111/ase-prep/mock-api/stage"
+	"github.com/Fancy11111/ase-prep/mock-api/token"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+type middleware func(http.Handler) http.Handler
+
+// chain wraps h in the middlewares, the first one being the outermost.
+func chain(h http.Handler, middlewares ...middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+type contextKey int
+
+const (
+	assignmentParamsKey contextKey = iota
+	problemLogKey
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// withRequestID assigns every request an ID, reusing the one of an upstream
+// proxy if present, and attaches a logger carrying it to the request context.
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if id == "" || len(id) > 64 {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+
+		logger := log.With().Str("requestId", id).Logger()
+		next.ServeHTTP(w, r.WithContext(logger.WithContext(r.Context())))
+	})
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// statusRecorder remembers the status code and body size of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (sr *statusRecorder) WriteHeader(status int) {
+	if sr.status == 0 {
+		sr.status = status
+	}
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+func (sr *statusRecorder) Write(b []byte) (int, error) {
+	if sr.status == 0 {
+		sr.status = http.StatusOK
+	}
+	n, err := sr.ResponseWriter.Write(b)
+	sr.bytes += int64(n)
+	return n, err
+}
+
+func (sr *statusRecorder) Flush() {
+	http.NewResponseController(sr.ResponseWriter).Flush()
+}
+
+// Unwrap gives http.ResponseController access to the wrapped writer.
+func (sr *statusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
+
+// withAccessLog writes one log line per request once it has been served.
+// Fields added to the request logger by inner middlewares end up in it too.
+func withAccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(recorder, r)
+
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+		log.Ctx(r.Context()).Info().
+			Str("method", r.Method).
+			Str("url", r.URL.Path).
+			Int("status", recorder.status).
+			Int64("bytes", recorder.bytes).
+			Dur("duration", time.Since(start)).
+			Msg("")
+	})
+}
+
+// withRecovery turns panics of handlers into 500 responses.
+func withRecovery(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if recovered == http.ErrAbortHandler {
+				// Used by net/http to abort a response, not an actual failure.
+				panic(recovered)
+			}
+
+			log.Ctx(r.Context()).Error().
+				Any("panic", recovered).
+				Bytes("stack", debug.Stack()).
+				Msg("Recovered from panic")
+			writeProblem(w, r, http.StatusInternalServerError, codeInternal, "Internal server error")
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AssignmentParams are the validated path parameters of an /assignment route.
+// stage and testcase are only set on routes containing them.
+type AssignmentParams struct {
+	mnr      string
+	stage    string
+	entry    stage.Entry
+	testcase int
+	token    string
+}
+
+func paramsFrom(ctx context.Context) AssignmentParams {
+	p, _ := ctx.Value(assignmentParamsKey).(AssignmentParams)
+	return p
+}
+
+// withAssignmentParams parses and validates the {mnr}, {stage} and
+// {testcase} path values as well as the token of an /assignment route.
+func (h Handler) withAssignmentParams(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := AssignmentParams{
+			mnr:   r.PathValue("mnr"),
+			stage: r.PathValue("stage"),
+			token: tokenFromRequest(r, h.allowQueryToken),
+		}
+
+		log.Ctx(r.Context()).UpdateContext(func(c zerolog.Context) zerolog.Context {
+			c = c.Str("mnr", p.mnr)
+			if p.stage != "" {
+				c = c.Str("stage", p.stage)
+			}
+			if testcase := r.PathValue("testcase"); testcase != "" {
+				c = c.Str("testcase", testcase)
+			}
+			if p.token != "" {
+				c = c.Str("token", token.Redact(p.token))
+			}
+			return c
+		})
+
+		if !h.mnrPattern.MatchString(p.mnr) {
+			writeProblem(w, r, http.StatusBadRequest, codeInvalidMnr,
+				fmt.Sprintf("Matriculation number %q does not match %s", p.mnr, h.mnrPattern))
+			return
+		}
+		if !h.roster.Allows(p.mnr) {
+			writeProblem(w, r, http.StatusForbidden, codeUnknownStudent,
+				fmt.Sprintf("Matriculation number %q is not enrolled", p.mnr))
+			return
+		}
+
+		if p.stage != "" {
+			entry, exists := h.stages.Get(p.stage)
+			if !exists {
+				writeProblem(w, r, http.StatusNotFound, codeUnknownStage, fmt.Sprintf("Stage %q does not exist", p.stage))
+				return
+			}
+			p.entry = entry
+		}
+
+		if testcase := r.PathValue("testcase"); testcase != "" {
+			testcaseNr, err := strconv.Atoi(testcase)
+			if err != nil {
+				writeProblem(w, r, http.StatusBadRequest, codeInvalidTestcaseNumber, "Could not parse testcase number")
+				return
+			}
+			if testcaseNr < 1 || testcaseNr > p.entry.Testcases {
+				writeProblem(w, r, http.StatusNotFound, codeUnknownTestcase,
+					fmt.Sprintf("Stage %q has the testcases 1 to %d", p.stage, p.entry.Testcases))
+				return
+			}
+			p.testcase = testcaseNr
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), assignmentParamsKey, p)))
+	})
+}
+
+// requireToken answers with 401 unless the request carries the token of
+// its mnr. It has to run after withAssignmentParams.
+func (h Handler) requireToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := paramsFrom(r.Context())
+
+		valid, err := h.tm.ValidateToken(p.mnr, p.token)
+		if !valid {
+			detail := "Missing token"
+			if p.token != "" && err != nil {
+				detail = err.Error()
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="assignment"`)
+			writeProblem(w, r, http.StatusUnauthorized, codeInvalidToken, detail)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
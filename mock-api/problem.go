@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Machine readable codes of the problems reported by the mock.
+const (
+	codeInvalidMnr            = "invalid_mnr"
+	codeUnknownStudent        = "unknown_student"
+	codeInvalidTestcaseNumber = "invalid_testcase_number"
+	codeInvalidToken          = "invalid_token"
+	codeUnknownStage          = "unknown_stage"
+	codeUnknownTestcase       = "unknown_testcase"
+	codeMalformedSolution     = "malformed_solution"
+	codeSolutionTooLarge      = "solution_too_large"
+	codeNotInteractive        = "not_interactive"
+	codeMalformedMove         = "malformed_move"
+	codeOutOfMoves            = "out_of_moves"
+	codeNotFinished           = "not_finished"
+	codeAdminDisabled         = "admin_disabled"
+	codeInvalidAdminSecret    = "invalid_admin_secret"
+	codeNoRoster              = "no_roster"
+	codeInvalidRoster         = "invalid_roster"
+	codeInvalidFilter         = "invalid_filter"
+	codeUnknownSubmission     = "unknown_submission"
+	codeShuttingDown          = "shutting_down"
+	codeInternal              = "internal_error"
+)
+
+// problem is an RFC 7807 problem details object, extended by a code clients
+// can switch on instead of parsing the detail message.
+type problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Code     string `json:"code"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// Errors optionally lists the individual problems, e.g. of fields.
+	Errors any `json:"errors,omitempty"`
+}
+
+// writeProblem answers the request with an application/problem+json body and
+// records the problem for the dashboard. Nothing may have been written to w
+// before.
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, code string, detail string) {
+	writeProblemErrors(w, r, status, code, detail, nil)
+}
+
+// writeProblemErrors is writeProblem with a list of individual errors.
+func writeProblemErrors(w http.ResponseWriter, r *http.Request, status int, code string, detail string, errors any) {
+	p := problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Code:     code,
+		Detail:   detail,
+		Instance: r.URL.Path,
+		Errors:   errors,
+	}
+
+	encoded, err := json.Marshal(p)
+	if err != nil {
+		log.Err(err).Msg("Could not marshal problem")
+	}
+
+	if problems, ok := r.Context().Value(problemLogKey).(*problemLog); ok {
+		problems.add(recentProblem{
+			Time:      time.Now(),
+			Status:    status,
+			Code:      code,
+			Detail:    detail,
+			Method:    r.Method,
+			Path:      r.URL.Path,
+			RequestID: w.Header().Get(requestIDHeader),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	w.Write(encoded)
+}
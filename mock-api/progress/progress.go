@@ -0,0 +1,208 @@
+package progress
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Status summarizes how far a student got.
+type Status string
+
+const (
+	NotStarted Status = "not-started"
+	Started    Status = "started"
+	Finished   Status = "finished"
+)
+
+// StageProgress is the progress of a student within one stage.
+type StageProgress struct {
+	Solved       []int `json:"solved"`
+	Attempts     int   `json:"attempts"`
+	LastTestcase int   `json:"lastTestcase"`
+	// StartedAt is when the first testcase of the stage was fetched.
+	StartedAt *time.Time `json:"startedAt,omitempty"`
+
+	// fetchedAt holds when each testcase was last fetched.
+	fetchedAt map[int]time.Time
+}
+
+// Progress is what the mock knows about the work of one student.
+type Progress struct {
+	Mnr           string                    `json:"mnr"`
+	Status        Status                    `json:"status"`
+	FirstTokenAt  *time.Time                `json:"firstTokenAt,omitempty"`
+	LastActiveAt  *time.Time                `json:"lastActiveAt,omitempty"`
+	FinishedAt    *time.Time                `json:"finishedAt,omitempty"`
+	TokensIssued  int                       `json:"tokensIssued"`
+	Stages        map[string]*StageProgress `json:"stages"`
+	SolvedTotal   int                       `json:"solvedTotal"`
+	AttemptsTotal int                       `json:"attemptsTotal"`
+}
+
+func (p *Progress) stage(id string) *StageProgress {
+	sp, exists := p.Stages[id]
+	if !exists {
+		sp = &StageProgress{Solved: []int{}, fetchedAt: map[int]time.Time{}}
+		p.Stages[id] = sp
+	}
+	return sp
+}
+
+func (p *Progress) clone() Progress {
+	c := *p
+	c.Stages = make(map[string]*StageProgress, len(p.Stages))
+	for id, sp := range p.Stages {
+		spClone := *sp
+		spClone.Solved = append([]int{}, sp.Solved...)
+		spClone.fetchedAt = nil
+		spClone.StartedAt = clonePtr(sp.StartedAt)
+		c.Stages[id] = &spClone
+	}
+	c.FirstTokenAt = clonePtr(p.FirstTokenAt)
+	c.LastActiveAt = clonePtr(p.LastActiveAt)
+	c.FinishedAt = clonePtr(p.FinishedAt)
+	return c
+}
+
+func clonePtr[T any](v *T) *T {
+	if v == nil {
+		return nil
+	}
+	c := *v
+	return &c
+}
+
+// Tracker records the progress of all students in memory.
+type Tracker struct {
+	mu       sync.RWMutex
+	students map[string]*Progress
+	now      func() time.Time
+}
+
+func NewTracker() *Tracker {
+	return &Tracker{
+		students: map[string]*Progress{},
+		now:      time.Now,
+	}
+}
+
+// touch returns the progress of mnr, creating it if necessary. The caller
+// must hold the write lock.
+func (t *Tracker) touch(mnr string) *Progress {
+	now := t.now()
+	p, exists := t.students[mnr]
+	if !exists {
+		p = &Progress{
+			Mnr:          mnr,
+			Status:       Started,
+			FirstTokenAt: &now,
+			Stages:       map[string]*StageProgress{},
+		}
+		t.students[mnr] = p
+	}
+	p.LastActiveAt = &now
+	return p
+}
+
+func (t *Tracker) TokenIssued(mnr string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.touch(mnr).TokensIssued++
+}
+
+func (t *Tracker) TestcaseFetched(mnr string, stage string, testcase int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	sp := t.touch(mnr).stage(stage)
+	now := t.now()
+	if sp.StartedAt == nil {
+		sp.StartedAt = &now
+	}
+	sp.LastTestcase = testcase
+	sp.fetchedAt[testcase] = now
+}
+
+// FetchedAt returns when mnr last fetched the testcase.
+func (t *Tracker) FetchedAt(mnr string, stage string, testcase int) (time.Time, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	p, exists := t.students[mnr]
+	if !exists {
+		return time.Time{}, false
+	}
+	sp, exists := p.Stages[stage]
+	if !exists {
+		return time.Time{}, false
+	}
+	fetchedAt, exists := sp.fetchedAt[testcase]
+	return fetchedAt, exists
+}
+
+func (t *Tracker) SolutionSubmitted(mnr string, stage string, testcase int, correct bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p := t.touch(mnr)
+	sp := p.stage(stage)
+	sp.Attempts++
+	p.AttemptsTotal++
+
+	if !correct {
+		return
+	}
+	i := sort.SearchInts(sp.Solved, testcase)
+	if i < len(sp.Solved) && sp.Solved[i] == testcase {
+		return
+	}
+	sp.Solved = append(sp.Solved, 0)
+	copy(sp.Solved[i+1:], sp.Solved[i:])
+	sp.Solved[i] = testcase
+	p.SolvedTotal++
+}
+
+// Finished marks mnr as finished and returns when the student finished
+// first, first tells whether that is now.
+func (t *Tracker) Finished(mnr string) (finishedAt time.Time, first bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p := t.touch(mnr)
+	if p.FinishedAt == nil {
+		now := t.now()
+		p.FinishedAt = &now
+		first = true
+	}
+	p.Status = Finished
+	return *p.FinishedAt, first
+}
+
+// Get returns the progress of mnr, with status NotStarted if there is none.
+func (t *Tracker) Get(mnr string) Progress {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	p, exists := t.students[mnr]
+	if !exists {
+		return Progress{Mnr: mnr, Status: NotStarted, Stages: map[string]*StageProgress{}}
+	}
+	return p.clone()
+}
+
+// All returns the progress of every student seen so far, ordered by mnr.
+func (t *Tracker) All() []Progress {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	all := make([]Progress, 0, len(t.students))
+	for _, p := range t.students {
+		all = append(all, p.clone())
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].Mnr < all[j].Mnr
+	})
+	return all
+}
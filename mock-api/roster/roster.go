@@ -0,0 +1,225 @@
+package roster
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrNoFile is returned by Reload if the roster is not backed by a file.
+var ErrNoFile = errors.New("roster is not backed by a file")
+
+// Student is an entry of the course roster.
+type Student struct {
+	Mnr   string `json:"mnr"`
+	Name  string `json:"name,omitempty"`
+	Group string `json:"group,omitempty"`
+}
+
+// Roster is the set of students allowed to use the mock, loaded from a CSV
+// file with the columns mnr, name and group. A header row naming the columns
+// may reorder them, otherwise that order is assumed and name and group are
+// optional. A roster without any student is rejected, as it would lock
+// everyone out.
+type Roster struct {
+	path string
+	// mnrPattern every listed mnr has to match, nil accepts any.
+	mnrPattern *regexp.Regexp
+
+	mu       sync.RWMutex
+	students map[string]Student
+	// enabled is set once a roster has been loaded or imported, before that
+	// every mnr is accepted.
+	enabled bool
+}
+
+// New creates a roster backed by the file at path, which is not read yet.
+// An empty path creates a roster that only changes by Import. Rosters
+// listing an mnr that does not match mnrPattern are rejected.
+func New(path string, mnrPattern *regexp.Regexp) *Roster {
+	return &Roster{path: path, mnrPattern: mnrPattern, students: map[string]Student{}}
+}
+
+// Reload reads the roster file again. The previous entries are kept if the
+// file can not be read or parsed.
+func (r *Roster) Reload() error {
+	if r.path == "" {
+		return ErrNoFile
+	}
+
+	f, err := os.Open(r.path)
+	if err != nil {
+		return fmt.Errorf("open roster: %w", err)
+	}
+	defer f.Close()
+
+	students, err := r.parse(f)
+	if err != nil {
+		return fmt.Errorf("parse roster %s: %w", r.path, err)
+	}
+
+	r.replace(students)
+	return nil
+}
+
+// Import replaces the roster with the CSV read from reader. If the roster is
+// backed by a file, the file is overwritten so a later Reload keeps the import.
+func (r *Roster) Import(reader io.Reader) error {
+	students, err := r.parse(reader)
+	if err != nil {
+		return fmt.Errorf("parse roster: %w", err)
+	}
+
+	if r.path != "" {
+		if err := write(r.path, students); err != nil {
+			return err
+		}
+	}
+
+	r.replace(students)
+	return nil
+}
+
+func (r *Roster) replace(students map[string]Student) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.students = students
+	r.enabled = true
+}
+
+func (r *Roster) parse(reader io.Reader) (map[string]Student, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+	csvReader.Comment = '#'
+
+	columns := map[string]int{"mnr": 0, "name": 1, "group": 2}
+	field := func(record []string, name string) string {
+		i, exists := columns[name]
+		if !exists || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	students := map[string]Student{}
+	for first := true; ; first = false {
+		record, err := csvReader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if first && isHeader(record) {
+			columns = map[string]int{}
+			for i, name := range record {
+				columns[strings.ToLower(strings.TrimSpace(name))] = i
+			}
+			continue
+		}
+
+		student := Student{
+			Mnr:   field(record, "mnr"),
+			Name:  field(record, "name"),
+			Group: field(record, "group"),
+		}
+		if student.Mnr == "" {
+			line, _ := csvReader.FieldPos(0)
+			return nil, fmt.Errorf("line %d: empty matriculation number", line)
+		}
+		if r.mnrPattern != nil && !r.mnrPattern.MatchString(student.Mnr) {
+			line, _ := csvReader.FieldPos(0)
+			return nil, fmt.Errorf("line %d: matriculation number %q does not match %s", line, student.Mnr, r.mnrPattern)
+		}
+		students[student.Mnr] = student
+	}
+	if len(students) == 0 {
+		return nil, errors.New("no students")
+	}
+	return students, nil
+}
+
+func isHeader(record []string) bool {
+	for _, name := range record {
+		if strings.EqualFold(strings.TrimSpace(name), "mnr") {
+			return true
+		}
+	}
+	return false
+}
+
+func write(path string, students map[string]Student) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("write roster: %w", err)
+	}
+	tmp := f.Name()
+
+	csvWriter := csv.NewWriter(f)
+	csvWriter.Write([]string{"mnr", "name", "group"})
+	for _, s := range sorted(students) {
+		csvWriter.Write([]string{s.Mnr, s.Name, s.Group})
+	}
+	csvWriter.Flush()
+
+	if err := errors.Join(csvWriter.Error(), f.Close()); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("write roster: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+func sorted(students map[string]Student) []Student {
+	list := make([]Student, 0, len(students))
+	for _, s := range students {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Mnr < list[j].Mnr
+	})
+	return list
+}
+
+// Allows reports whether mnr may use the mock.
+func (r *Roster) Allows(mnr string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if !r.enabled {
+		return true
+	}
+	_, exists := r.students[mnr]
+	return exists
+}
+
+func (r *Roster) Get(mnr string) (Student, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	s, exists := r.students[mnr]
+	return s, exists
+}
+
+// Students returns all students ordered by mnr.
+func (r *Roster) Students() []Student {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return sorted(r.students)
+}
+
+func (r *Roster) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return len(r.students)
+}
@@ -0,0 +1,60 @@
+package roster
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var mnrPattern = regexp.MustCompile(`^[0-9]{8}$`)
+
+func TestImportRejectsInvalidRosters(t *testing.T) {
+	for name, csv := range map[string]string{
+		"empty":       "",
+		"header only": "mnr,name,group\n",
+		"comments":    "# no students yet\n",
+		"empty mnr":   "12345678\n,Jane\n",
+		"bad mnr":     "12345678\n1234,Jane\n",
+	} {
+		r := New("", mnrPattern)
+		if err := r.Import(strings.NewReader("87654321\n")); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := r.Import(strings.NewReader(csv)); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+		if !r.Allows("87654321") || r.Len() != 1 {
+			t.Errorf("%s: the previous roster was not kept", name)
+		}
+	}
+}
+
+func TestImportWritesRosterFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "roster.csv")
+	r := New(path, mnrPattern)
+
+	if err := r.Import(strings.NewReader("name,mnr\nJane,12345678\n")); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := r.Get("12345678"); s.Name != "Jane" {
+		t.Errorf("Get() = %+v, want Jane", s)
+	}
+
+	if err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if !r.Allows("12345678") || r.Allows("87654321") {
+		t.Errorf("reloaded roster lists %v", r.Students())
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("directory holds %d files, want only the roster", len(entries))
+	}
+}
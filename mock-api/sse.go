@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// startEventStream prepares w for server-sent events.
+func startEventStream(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// Keeps reverse proxies like nginx from buffering the stream.
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+}
+
+// writeEvent sends v encoded as JSON in an event of the given type and
+// flushes it to the client. An empty id is left out.
+func writeEvent(w http.ResponseWriter, id string, event string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if id != "" {
+		if _, err := fmt.Fprintf(w, "id: %s\n", id); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+		return err
+	}
+	return http.NewResponseController(w).Flush()
+}
+
+// writeKeepAlive sends a comment, which keeps idle connections from being
+// closed by proxies.
+func writeKeepAlive(w http.ResponseWriter) error {
+	if _, err := io.WriteString(w, ": keep-alive\n\n"); err != nil {
+		return err
+	}
+	return http.NewResponseController(w).Flush()
+}
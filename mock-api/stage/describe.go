@@ -0,0 +1,49 @@
+package stage
+
+import (
+	"embed"
+	"encoding/json"
+)
+
+//go:embed docs
+var docs embed.FS
+
+// Description documents a stage for students.
+type Description struct {
+	Title string `json:"title"`
+	// Statement is the problem statement in Markdown.
+	Statement string  `json:"statement"`
+	Schemas   Schemas `json:"schemas"`
+	Example   Example `json:"example"`
+	// Normalization tells which differences are ignored in the text fields
+	// of a solution, keyed by the JSON pointer of the field.
+	Normalization map[string]Normalization `json:"normalization,omitempty"`
+}
+
+// Schemas are the JSON Schemas of the testcases and solutions of a stage,
+// and of the moves of interactive stages.
+type Schemas struct {
+	Testcase json.RawMessage `json:"testcase,omitempty"`
+	Solution json.RawMessage `json:"solution,omitempty"`
+	Move     json.RawMessage `json:"move,omitempty"`
+}
+
+// Example is a small testcase along with its solution.
+type Example struct {
+	Testcase any `json:"testcase,omitempty"`
+	Solution any `json:"solution,omitempty"`
+}
+
+// Describer is implemented by stages documenting themselves.
+type Describer interface {
+	Describe() Description
+}
+
+// doc returns the embedded file docs/name, which has to exist.
+func doc(name string) []byte {
+	content, err := docs.ReadFile("docs/" + name)
+	if err != nil {
+		panic(err)
+	}
+	return content
+}
@@ -0,0 +1,107 @@
+package stage
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+//go:embed cases
+var cases embed.FS
+
+// DefaultEdgeCaseInterval is how many generated testcases precede every
+// curated one, unless Options.EdgeCaseInterval says otherwise.
+const DefaultEdgeCaseInterval = 1
+
+// edgeCase is an entry of a <kind>.json cases file. The name only documents
+// what the testcase is about.
+type edgeCase[C any] struct {
+	Name     string `json:"name"`
+	Testcase C      `json:"testcase"`
+}
+
+// edgeCases are curated testcases inserted between the generated ones of a
+// stage. The curated testcases do not replace generated ones, a stage with
+// n generated testcases serves testcases(n) in total.
+type edgeCases[C any] struct {
+	curated []C
+	// interval generated testcases precede every curated one, none are
+	// inserted if it is not positive.
+	interval int
+}
+
+// loadEdgeCases reads the curated testcases of kind from <kind>.json in
+// opts.CasesDir, or from the embedded cases directory if there is no such
+// file. A kind without any cases file has no edge cases. validate, if not
+// nil, rejects curated testcases the stage cannot serve.
+func loadEdgeCases[C any](kind string, opts Options, validate func(C) error) (edgeCases[C], error) {
+	e := edgeCases[C]{interval: opts.EdgeCaseInterval}
+	if e.interval == 0 {
+		e.interval = DefaultEdgeCaseInterval
+	}
+
+	name := kind + ".json"
+	content, err := fs.ReadFile(cases, "cases/"+name)
+	if opts.CasesDir != "" {
+		path := filepath.Join(opts.CasesDir, name)
+		if override, overrideErr := os.ReadFile(path); overrideErr == nil {
+			name, content, err = path, override, nil
+		} else if !errors.Is(overrideErr, fs.ErrNotExist) {
+			return e, overrideErr
+		}
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return e, nil
+	} else if err != nil {
+		return e, err
+	}
+
+	var entries []edgeCase[C]
+	if err := json.Unmarshal(content, &entries); err != nil {
+		return e, fmt.Errorf("parse %s: %w", name, err)
+	}
+	e.curated = make([]C, len(entries))
+	for i, entry := range entries {
+		if validate != nil {
+			if err := validate(entry.Testcase); err != nil {
+				return e, fmt.Errorf("%s: case %q: %w", name, entry.Name, err)
+			}
+		}
+		e.curated[i] = entry.Testcase
+	}
+	return e, nil
+}
+
+// testcases returns how many testcases are served for the given number of
+// generated ones. Curated testcases only go between generated ones, so the
+// last and largest testcase is always a generated one.
+func (e edgeCases[C]) testcases(generated int) int {
+	if len(e.curated) == 0 || e.interval <= 0 || generated < 1 {
+		return generated
+	}
+	return generated + (generated-1)/e.interval
+}
+
+// pick returns the curated testcase if testcase nr is one. Otherwise it
+// returns the number of the generated testcase served as nr, which is the
+// number it would have without edge cases. The order of the curated
+// testcases depends on the token, so students get to see different ones
+// when there are more than slots for them.
+func (e edgeCases[C]) pick(token string, nr int) (C, int, bool) {
+	var none C
+	if len(e.curated) == 0 || e.interval <= 0 || nr < 1 {
+		return none, nr, false
+	}
+
+	block, position := (nr-1)/(e.interval+1), (nr-1)%(e.interval+1)
+	if position < e.interval {
+		return none, block*e.interval + position + 1, false
+	}
+	// Generated testcases start at 1, so 0 does not repeat their seed.
+	order := RandFromTokenAndTestcase(token, 0).Perm(len(e.curated))
+	return e.curated[order[block%len(e.curated)]], 0, true
+}
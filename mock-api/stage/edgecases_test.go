@@ -0,0 +1,238 @@
+package stage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEdgeCasesAreInsertedBetweenGeneratedTestcases(t *testing.T) {
+	tests := []struct {
+		interval  int
+		generated int
+		// want has a g for every generated and a c for every curated
+		// testcase, in the order they are served.
+		want string
+	}{
+		{1, 1, "g"},
+		{1, 4, "gcgcgcg"},
+		{2, 5, "ggcggcg"},
+		{2, 6, "ggcggcgg"},
+		{3, 3, "ggg"},
+		{-1, 4, "gggg"},
+	}
+
+	for _, tt := range tests {
+		e := edgeCases[int]{curated: []int{-1, -2}, interval: tt.interval}
+		if got := e.testcases(tt.generated); got != len(tt.want) {
+			t.Errorf("interval %d: %d generated testcases serve %d, want %d", tt.interval, tt.generated, got, len(tt.want))
+		}
+
+		var served strings.Builder
+		next := 1
+		for nr := 1; nr <= len(tt.want); nr++ {
+			_, generated, curated := e.pick("token", nr)
+			if curated {
+				served.WriteByte('c')
+				continue
+			}
+			served.WriteByte('g')
+			if generated != next {
+				t.Errorf("interval %d: testcase %d serves generated testcase %d, want %d", tt.interval, nr, generated, next)
+			}
+			next++
+		}
+		if served.String() != tt.want {
+			t.Errorf("interval %d: served %s, want %s", tt.interval, served.String(), tt.want)
+		}
+	}
+}
+
+func TestEdgeCasesCycleInTokenOrder(t *testing.T) {
+	e := edgeCases[int]{curated: []int{1, 2, 3}, interval: 1}
+
+	seen := map[int]bool{}
+	for nr := 2; nr <= 6; nr += 2 {
+		c, _, curated := e.pick("token", nr)
+		if !curated {
+			t.Fatalf("testcase %d is not curated", nr)
+		}
+		seen[c] = true
+	}
+	if len(seen) != 3 {
+		t.Errorf("the first three curated testcases are %v, want all of them", seen)
+	}
+	if first, _, _ := e.pick("token", 2); first != mustPick(t, e, "token", 8) {
+		t.Error("the curated testcases do not repeat in the same order")
+	}
+}
+
+func mustPick(t *testing.T, e edgeCases[int], token string, nr int) int {
+	t.Helper()
+	c, _, curated := e.pick(token, nr)
+	if !curated {
+		t.Fatalf("testcase %d is not curated", nr)
+	}
+	return c
+}
+
+// checkCuratedSolutions submits the reference solution of every curated
+// testcase of s, which has to match the solution schema and be accepted.
+// With DefaultEdgeCaseInterval every even testcase is a curated one.
+func checkCuratedSolutions[T any, S any](t *testing.T, kind string, s Stage[T, S], curated int) {
+	t.Helper()
+
+	if curated == 0 {
+		t.Errorf("%s has no curated testcases", kind)
+	}
+	runner, err := NewRunner(s, Options{Strict: true})
+	if err != nil {
+		t.Fatalf("%s: %v", kind, err)
+	}
+	for i := range curated {
+		nr := 2 * (i + 1)
+		solution, err := json.Marshal(s.GetSolution("token", nr))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok, err := runner.Validate("token", nr, bytes.NewReader(solution)); !ok || err != nil {
+			t.Errorf("%s testcase %d: reference solution %s rejected: %v", kind, nr, solution, err)
+		}
+	}
+}
+
+func TestEmbeddedEdgeCasesAreSolved(t *testing.T) {
+	pointsC, pointsCErr := NewStagePointC(Options{})
+	polygon, polygonErr := NewStagePolygon(Options{})
+	graph, graphErr := NewStageShortestPath(Options{})
+	text, textErr := NewStageText(Options{})
+	if err := errors.Join(pointsCErr, polygonErr, graphErr, textErr); err != nil {
+		t.Fatal(err)
+	}
+
+	checkCuratedSolutions(t, "points-c", pointsC, len(pointsC.curated))
+	checkCuratedSolutions(t, "polygon", polygon, len(polygon.curated))
+	checkCuratedSolutions(t, "shortest-path", graph, len(graph.curated))
+	checkCuratedSolutions(t, "text", text, len(text.curated))
+}
+
+func TestCuratedMazesCanBeSolved(t *testing.T) {
+	s, err := NewStageMaze(Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for nr := 2; nr <= s.testcases(len(s.curated)+1); nr += 2 {
+		m := s.layout("token", nr)
+		path := pathTo(m, m.exit)
+		if path == nil {
+			t.Fatalf("testcase %d: no path to the exit at %v", nr, m.exit)
+		}
+		for _, move := range path {
+			if _, err := s.Move("token", nr, MazeMove{Move: move}); err != nil {
+				t.Fatalf("testcase %d: move %s: %v", nr, move, err)
+			}
+		}
+		if !s.ValidateSolution("token", nr, MazeSolution{Exit: m.exit}) {
+			t.Errorf("testcase %d: exit %v rejected after reaching it", nr, m.exit)
+		}
+	}
+}
+
+// pathTo returns the moves of a shortest path from the start to p, nil if
+// there is none.
+func pathTo(m maze, p Position) []string {
+	moves := map[Position][]string{{}: {}}
+	queue := []Position{{}}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if current == p {
+			return moves[current]
+		}
+		for i, d := range directions {
+			next := Position{X: current.X + d.dx, Y: current.Y + d.dy}
+			if _, seen := moves[next]; !seen && m.open[m.index(current)]&(1<<i) != 0 {
+				moves[next] = append(append([]string{}, moves[current]...), d.name)
+				queue = append(queue, next)
+			}
+		}
+	}
+	return nil
+}
+
+func TestMazeDrawing(t *testing.T) {
+	var m maze
+	drawing := `["+-+-+", "|   |", "+ +-+", "|E  |", "+-+-+"]`
+	if err := json.Unmarshal([]byte(drawing), &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.width != 2 || m.height != 2 || m.exit != (Position{X: 0, Y: 1}) {
+		t.Errorf("maze is %dx%d with the exit at %v, want 2x2 with the exit at {0 1}", m.width, m.height, m.exit)
+	}
+	for p, want := range map[Position][]string{
+		{X: 0, Y: 0}: {"east", "south"},
+		{X: 1, Y: 0}: {"west"},
+		{X: 0, Y: 1}: {"north", "east"},
+		{X: 1, Y: 1}: {"west"},
+	} {
+		if got := m.openDirections(p); strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("open at %v = %v, want %v", p, got, want)
+		}
+	}
+
+	for name, drawing := range map[string]string{
+		"no exit":          `["+-+-+", "|   |", "+-+-+"]`,
+		"exit at start":    `["+-+-+", "|E  |", "+-+-+"]`,
+		"open border":      `["+-+-+", "   E|", "+-+-+"]`,
+		"unreachable exit": `["+-+-+", "| |E|", "+-+-+"]`,
+		"ragged lines":     `["+-+-+", "|  E|", "+-+"]`,
+		"even lines":       `["+-+-+", "|  E|"]`,
+	} {
+		if err := json.Unmarshal([]byte(drawing), &m); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
+
+func TestCasesDirReplacesEmbeddedCases(t *testing.T) {
+	dir := t.TempDir()
+	testcase := TextTestCase{Shift: 1, Ciphertext: "Ifmmp"}
+	content, _ := json.Marshal([]edgeCase[TextTestCase]{{Name: "hello", Testcase: testcase}})
+	if err := os.WriteFile(filepath.Join(dir, "text.json"), content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewStageText(Options{CasesDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.CreateTestcase("token", 2); got != testcase {
+		t.Errorf("curated testcase = %+v, want %+v", got, testcase)
+	}
+
+	// Kinds without a file in the directory keep the embedded cases.
+	polygon, err := NewStagePolygon(Options{CasesDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(polygon.curated) == 0 {
+		t.Error("polygon lost its embedded cases")
+	}
+}
+
+func TestInvalidCasesAreRejected(t *testing.T) {
+	dir := t.TempDir()
+	unreachable := `[{"name": "unreachable", "testcase": {"nodes": 2, "edges": [], "start": 0, "target": 1}}]`
+	if err := os.WriteFile(filepath.Join(dir, "shortest-path.json"), []byte(unreachable), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := New("shortest-path", Options{CasesDir: dir}); err == nil || !strings.Contains(err.Error(), "unreachable") {
+		t.Errorf("New() = %v, want an error naming the unreachable case", err)
+	}
+}
@@ -0,0 +1,411 @@
+package stage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+)
+
+// FileKind is the kind of the entries created by LoadFileStages.
+const FileKind = "file"
+
+const manifestName = "manifest.yaml"
+
+// maxFileTestcases bounds the testcases a manifest may declare.
+const maxFileTestcases = 100
+
+// FileManifest is the manifest.yaml of a file stage directory. File names
+// are relative to the directory.
+type FileManifest struct {
+	// ID defaults to the name of the directory.
+	ID    string `yaml:"id"`
+	Title string `yaml:"title"`
+	// Statement is a Markdown file, statement.md if it exists. An example
+	// is read from example.input.json and example.output.json if they exist.
+	Statement string `yaml:"statement"`
+	// Testcases is required with a generator, otherwise it defaults to the
+	// number of testcases/<nr>.input.json files.
+	Testcases int `yaml:"testcases"`
+	// Generator is a Go template rendering the JSON object
+	// {"testcase": ..., "solution": ...} for a token and testcase number.
+	// Without a generator, the testcases are read from
+	// testcases/<nr>.input.json and the solutions from
+	// testcases/<nr>.output.json.
+	Generator      string `yaml:"generator"`
+	TestcaseSchema string `yaml:"testcaseSchema"`
+	SolutionSchema string `yaml:"solutionSchema"`
+	// Normalization applies to every string of a solution, its IgnoreOrder
+	// to every array.
+	Normalization Normalization `yaml:"normalization"`
+}
+
+// FileStage is a stage defined by the files of a directory instead of Go
+// code, see FileManifest.
+type FileStage struct {
+	manifest  FileManifest
+	statement string
+	schemas   Schemas
+	// example holds the optional example.input.json and example.output.json.
+	example struct {
+		Testcase, Solution json.RawMessage
+	}
+	// inputs and outputs hold the testcases without a generator.
+	inputs    []json.RawMessage
+	outputs   []json.RawMessage
+	generator *template.Template
+}
+
+// generated is what the generator template renders.
+type generated struct {
+	Testcase json.RawMessage `json:"testcase"`
+	Solution json.RawMessage `json:"solution"`
+}
+
+// LoadFileStages loads every subdirectory of root containing a manifest as
+// a stage. Directories without one are skipped.
+func LoadFileStages(root string, opts Options) ([]Entry, error) {
+	dirs, err := os.ReadDir(root)
+	if err != nil {
+		return nil, fmt.Errorf("read stage directory: %w", err)
+	}
+
+	var entries []Entry
+	var errs []error
+	for _, d := range dirs {
+		dir := filepath.Join(root, d.Name())
+		if !d.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, manifestName)); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+
+		s, err := LoadFileStage(dir)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		runner, err := NewRunner(s, opts)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("stage %s: %w", dir, err))
+			continue
+		}
+		entries = append(entries, Entry{
+			ID:        s.manifest.ID,
+			Kind:      FileKind,
+			Testcases: s.manifest.Testcases,
+			Runner:    runner,
+		})
+	}
+	return entries, errors.Join(errs...)
+}
+
+// LoadFileStage reads the stage in dir. Generators are tried on every
+// testcase, so template errors show up here instead of when serving.
+func LoadFileStage(dir string) (FileStage, error) {
+	var s FileStage
+	wrap := func(err error) error {
+		return fmt.Errorf("stage %s: %w", dir, err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, manifestName))
+	if err != nil {
+		return s, wrap(err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&s.manifest); err != nil {
+		return s, wrap(fmt.Errorf("parse %s: %w", manifestName, err))
+	}
+	if s.manifest.ID == "" {
+		s.manifest.ID = filepath.Base(dir)
+	}
+	if s.manifest.Title == "" {
+		s.manifest.Title = s.manifest.ID
+	}
+
+	statement := s.manifest.Statement
+	if statement == "" {
+		if _, err := os.Stat(filepath.Join(dir, "statement.md")); err == nil {
+			statement = "statement.md"
+		}
+	}
+	if statement != "" {
+		content, err := os.ReadFile(filepath.Join(dir, statement))
+		if err != nil {
+			return s, wrap(err)
+		}
+		s.statement = string(content)
+	}
+
+	files := []struct {
+		name   string
+		target *json.RawMessage
+	}{
+		{s.manifest.TestcaseSchema, &s.schemas.Testcase},
+		{s.manifest.SolutionSchema, &s.schemas.Solution},
+		{"example.input.json", &s.example.Testcase},
+		{"example.output.json", &s.example.Solution},
+	}
+	for _, f := range files {
+		if f.name == "" {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(dir, f.name))
+		if errors.Is(err, os.ErrNotExist) && strings.HasPrefix(f.name, "example.") {
+			continue
+		}
+		if err != nil {
+			return s, wrap(err)
+		}
+		if !json.Valid(content) {
+			return s, wrap(fmt.Errorf("%s is not valid JSON", f.name))
+		}
+		*f.target = content
+	}
+
+	if s.manifest.Generator != "" {
+		err = s.loadGenerator(dir)
+	} else {
+		err = s.loadTestcases(dir)
+	}
+	if err != nil {
+		return s, wrap(err)
+	}
+	if s.manifest.Testcases < 1 {
+		return s, wrap(errors.New("no testcases"))
+	}
+	if s.manifest.Testcases > maxFileTestcases {
+		return s, wrap(fmt.Errorf("testcases: may be at most %d", maxFileTestcases))
+	}
+	return s, nil
+}
+
+func (s *FileStage) loadGenerator(dir string) error {
+	if s.manifest.Testcases < 1 {
+		return errors.New("testcases: required with a generator")
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, s.manifest.Generator))
+	if err != nil {
+		return err
+	}
+	s.generator, err = template.New(s.manifest.Generator).Funcs(generatorFuncs(nil)).Parse(string(content))
+	if err != nil {
+		return err
+	}
+
+	for nr := 1; nr <= s.manifest.Testcases; nr++ {
+		if _, err := s.generate("check", nr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *FileStage) loadTestcases(dir string) error {
+	for nr := 1; ; nr++ {
+		input, err := os.ReadFile(filepath.Join(dir, "testcases", fmt.Sprintf("%d.input.json", nr)))
+		if errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		output, err := os.ReadFile(filepath.Join(dir, "testcases", fmt.Sprintf("%d.output.json", nr)))
+		if err != nil {
+			return err
+		}
+		if !json.Valid(input) || !json.Valid(output) {
+			return fmt.Errorf("testcase %d is not valid JSON", nr)
+		}
+		s.inputs = append(s.inputs, input)
+		s.outputs = append(s.outputs, output)
+	}
+
+	switch {
+	case s.manifest.Testcases == 0:
+		s.manifest.Testcases = len(s.inputs)
+	case s.manifest.Testcases > len(s.inputs):
+		return fmt.Errorf("testcases: %d declared, but only %d found", s.manifest.Testcases, len(s.inputs))
+	}
+	return nil
+}
+
+// generate renders the generator for a testcase with a random source
+// seeded like the ones of the Go stages.
+func (s FileStage) generate(token string, nr int) (generated, error) {
+	var buf bytes.Buffer
+	randGen := RandFromTokenAndTestcase(token, nr)
+	data := map[string]any{"Token": token, "Nr": nr}
+	if err := template.Must(s.generator.Clone()).Funcs(generatorFuncs(randGen)).Execute(&buf, data); err != nil {
+		return generated{}, err
+	}
+
+	var g generated
+	if err := json.Unmarshal(buf.Bytes(), &g); err != nil {
+		return g, fmt.Errorf("testcase %d: generator output is not valid JSON: %w", nr, err)
+	}
+	if g.Testcase == nil || g.Solution == nil {
+		return g, fmt.Errorf("testcase %d: generator output needs a testcase and a solution", nr)
+	}
+	return g, nil
+}
+
+// testcase returns the testcase and solution nr, generated ones can fail
+// for tokens other than the one tried when loading.
+func (s FileStage) testcase(token string, nr int) (generated, error) {
+	if s.generator == nil {
+		if nr < 1 || nr > len(s.inputs) {
+			return generated{}, fmt.Errorf("testcase %d does not exist", nr)
+		}
+		return generated{Testcase: s.inputs[nr-1], Solution: s.outputs[nr-1]}, nil
+	}
+
+	g, err := s.generate(token, nr)
+	if err != nil {
+		return g, fmt.Errorf("stage %s: %w", s.manifest.ID, err)
+	}
+	return g, nil
+}
+
+func (s FileStage) generateTestcase(token string, nr int) (json.RawMessage, error) {
+	g, err := s.testcase(token, nr)
+	return g.Testcase, err
+}
+
+// CreateTestcase returns nil if the testcase cannot be generated, which
+// generateTestcase reports to the Runner.
+func (s FileStage) CreateTestcase(token string, nr int) json.RawMessage {
+	g, _ := s.testcase(token, nr)
+	return g.Testcase
+}
+
+func (s FileStage) GetSolution(token string, nr int) any {
+	g, err := s.testcase(token, nr)
+	if err != nil {
+		log.Err(err).Msg("Could not generate solution")
+		return nil
+	}
+	var solution any
+	json.Unmarshal(g.Solution, &solution)
+	return solution
+}
+
+// ValidateSolution compares the solution with the expected one as JSON
+// values, after normalizing both.
+func (s FileStage) ValidateSolution(token string, nr int, solution any) bool {
+	expected := s.GetSolution(token, nr)
+	if expected == nil {
+		return false
+	}
+	n := s.manifest.Normalization
+	return reflect.DeepEqual(normalizeJSON(expected, n), normalizeJSON(solution, n))
+}
+
+func (s FileStage) Describe() Description {
+	d := Description{
+		Title:     s.manifest.Title,
+		Statement: s.statement,
+		Schemas:   s.schemas,
+	}
+	if s.example.Testcase != nil {
+		d.Example.Testcase = s.example.Testcase
+	}
+	if s.example.Solution != nil {
+		d.Example.Solution = s.example.Solution
+	}
+	if !s.manifest.Normalization.IsZero() {
+		d.Normalization = map[string]Normalization{"/": s.manifest.Normalization}
+	}
+	return d
+}
+
+// normalizeJSON applies n to every string of a decoded JSON value and sorts
+// its arrays if the order is ignored.
+func normalizeJSON(v any, n Normalization) any {
+	switch v := v.(type) {
+	case string:
+		return n.Apply(v)
+	case []any:
+		normalized := make([]any, len(v))
+		for i, item := range v {
+			normalized[i] = normalizeJSON(item, n)
+		}
+		if n.IgnoreOrder {
+			slices.SortFunc(normalized, func(a, b any) int {
+				x, _ := json.Marshal(a)
+				y, _ := json.Marshal(b)
+				return bytes.Compare(x, y)
+			})
+		}
+		return normalized
+	case map[string]any:
+		normalized := make(map[string]any, len(v))
+		for key, item := range v {
+			normalized[key] = normalizeJSON(item, n)
+		}
+		return normalized
+	}
+	return v
+}
+
+// generatorFuncs are the functions of generator templates, the random ones
+// draw from randGen.
+func generatorFuncs(randGen *rand.Rand) template.FuncMap {
+	return template.FuncMap{
+		// randInt returns a number in [min, max].
+		"randInt": func(min, max int) int {
+			return min + randGen.Intn(max-min+1)
+		},
+		"randInts": func(n, min, max int) []int {
+			numbers := make([]int, n)
+			for i := range numbers {
+				numbers[i] = min + randGen.Intn(max-min+1)
+			}
+			return numbers
+		},
+		"randFloat": func(min, max float64) float64 {
+			return min + randGen.Float64()*(max-min)
+		},
+		"pick": func(options ...any) any {
+			return options[randGen.Intn(len(options))]
+		},
+		// seq returns 0, 1, ..., n-1.
+		"seq": func(n int) []int {
+			numbers := make([]int, n)
+			for i := range numbers {
+				numbers[i] = i
+			}
+			return numbers
+		},
+		"sum": func(numbers []int) int {
+			total := 0
+			for _, n := range numbers {
+				total += n
+			}
+			return total
+		},
+		"sorted": func(numbers []int) []int {
+			return slices.Sorted(slices.Values(numbers))
+		},
+		"add": func(a, b int) int { return a + b },
+		"sub": func(a, b int) int { return a - b },
+		"mul": func(a, b int) int { return a * b },
+		"json": func(v any) (string, error) {
+			content, err := json.Marshal(v)
+			return string(content), err
+		},
+	}
+}
@@ -0,0 +1,182 @@
+package stage
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func loadTestStages(t *testing.T) map[string]Entry {
+	t.Helper()
+
+	entries, err := LoadFileStages(filepath.Join("testdata", "stages"), Options{Strict: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	byID := map[string]Entry{}
+	for _, e := range entries {
+		byID[e.ID] = e
+	}
+	return byID
+}
+
+func TestLoadFileStages(t *testing.T) {
+	entries := loadTestStages(t)
+
+	want := map[string]int{"static": 2, "gen": 3, "flaky": 2}
+	if len(entries) != len(want) {
+		t.Errorf("loaded stages %v, want %v without the directory lacking a manifest", entries, want)
+	}
+	for id, testcases := range want {
+		e, exists := entries[id]
+		if !exists {
+			t.Errorf("stage %s was not loaded", id)
+			continue
+		}
+		if e.Kind != FileKind || e.Testcases != testcases {
+			t.Errorf("stage %s is a %s stage with %d testcases, want a %s stage with %d", id, e.Kind, e.Testcases, FileKind, testcases)
+		}
+	}
+}
+
+func TestFileManifestDefaults(t *testing.T) {
+	s, err := LoadFileStage(filepath.Join("testdata", "stages", "static"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a manifest entry the ID and title are the directory name, the
+	// statement is statement.md and the testcases are counted.
+	d := s.Describe()
+	if s.manifest.ID != "static" || d.Title != "static" || s.manifest.Testcases != 2 {
+		t.Errorf("manifest = %+v, title %q, want id and title static with 2 testcases", s.manifest, d.Title)
+	}
+	if !strings.HasPrefix(d.Statement, "# Static") {
+		t.Errorf("statement = %q, want the one of statement.md", d.Statement)
+	}
+	if d.Schemas.Solution == nil || d.Schemas.Testcase != nil {
+		t.Errorf("schemas = %+v, want only a solution schema", d.Schemas)
+	}
+	if d.Example.Testcase != nil || d.Example.Solution != nil {
+		t.Errorf("example = %+v, want none without example files", d.Example)
+	}
+}
+
+func TestFileStageIgnoresOrderAndCase(t *testing.T) {
+	runner := loadTestStages(t)["static"].Runner
+
+	tests := []struct {
+		solution string
+		want     bool
+	}{
+		{`{"words": ["c", "a", "c"]}`, true},
+		{`{"words": ["A", "c", "C"]}`, true},
+		{`{"words": ["a", "c"]}`, false},
+		{`{"words": ["a", "a", "c"]}`, false},
+	}
+	for _, tt := range tests {
+		if ok, err := runner.Validate("token", 2, strings.NewReader(tt.solution)); ok != tt.want || err != nil {
+			t.Errorf("Validate(%s) = %v, %v, want %v", tt.solution, ok, err, tt.want)
+		}
+	}
+}
+
+func TestNormalizeJSON(t *testing.T) {
+	decode := func(s string) any {
+		var v any
+		if err := json.Unmarshal([]byte(s), &v); err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	n := Normalization{TrimSpace: true, IgnoreOrder: true}
+
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{`[" b", "a "]`, `["a", "b"]`, true},
+		{`{"x": [2, 1], "y": [{"z": [" q", "p"]}]}`, `{"x": [1, 2], "y": [{"z": ["p", "q"]}]}`, true},
+		{`[[1, 2], [3]]`, `[[3], [2, 1]]`, true},
+		{`[1, 1, 2]`, `[1, 2, 2]`, false},
+		{`{"x": "A"}`, `{"x": "a"}`, false},
+		{`[1]`, `["1"]`, false},
+	}
+	for _, tt := range tests {
+		got := reflect.DeepEqual(normalizeJSON(decode(tt.a), n), normalizeJSON(decode(tt.b), n))
+		if got != tt.want {
+			t.Errorf("%s equals %s = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestFileStageGenerator(t *testing.T) {
+	runner := loadTestStages(t)["gen"].Runner
+
+	for nr := 1; nr <= 3; nr++ {
+		testcase, err := runner.Testcase("token", nr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var input struct{ Numbers []int }
+		if err := json.Unmarshal(testcase.(json.RawMessage), &input); err != nil {
+			t.Fatal(err)
+		}
+		if len(input.Numbers) != nr {
+			t.Errorf("testcase %d has %d numbers, want %d", nr, len(input.Numbers), nr)
+		}
+
+		sum := 0
+		for _, n := range input.Numbers {
+			sum += n
+		}
+		solution, _ := json.Marshal(map[string]int{"sum": sum})
+		if ok, err := runner.Validate("token", nr, bytes.NewReader(solution)); !ok || err != nil {
+			t.Errorf("testcase %d: sum %s rejected: %v", nr, solution, err)
+		}
+	}
+
+	again, _ := runner.Testcase("token", 3)
+	other, _ := runner.Testcase("other", 3)
+	if first, _ := runner.Testcase("token", 3); !bytes.Equal(first.(json.RawMessage), again.(json.RawMessage)) || bytes.Equal(first.(json.RawMessage), other.(json.RawMessage)) {
+		t.Error("generated testcases do not depend on exactly the token and number")
+	}
+}
+
+func TestFileStageGeneratorErrors(t *testing.T) {
+	runner := loadTestStages(t)["flaky"].Runner
+
+	if _, err := runner.Testcase("check", 1); err != nil {
+		t.Errorf("Testcase() for the token tried when loading: %v", err)
+	}
+	if testcase, err := runner.Testcase("token", 1); err == nil {
+		t.Errorf("Testcase() = %s, want the error of the generator", testcase)
+	}
+	if ok, _ := runner.Validate("token", 1, strings.NewReader(`{"nr": 1}`)); ok {
+		t.Error("solution accepted without a testcase")
+	}
+}
+
+func TestLoadFileStageErrors(t *testing.T) {
+	tests := map[string]string{
+		"missing-output":    "1.output.json",
+		"too-few-testcases": "2 declared, but only 1 found",
+		"invalid-output":    "generator output is not valid JSON",
+		"no-solution":       "needs a testcase and a solution",
+		"no-testcases":      "required with a generator",
+	}
+	for dir, want := range tests {
+		_, err := LoadFileStage(filepath.Join("testdata", "invalid", dir))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: LoadFileStage() = %v, want an error containing %q", dir, err, want)
+		}
+	}
+
+	entries, err := LoadFileStages(filepath.Join("testdata", "invalid"), Options{})
+	if len(entries) != 0 || err == nil {
+		t.Errorf("LoadFileStages() = %v, %v, want no stages and an error", entries, err)
+	}
+}
@@ -0,0 +1,245 @@
+package stage
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// StageShortestPath asks for a cheapest path between two nodes of a
+// weighted directed graph. Any path with the optimal cost is accepted.
+type StageShortestPath struct {
+	edgeCases[GraphTestCase]
+}
+
+type Edge struct {
+	From   int `json:"from"`
+	To     int `json:"to"`
+	Weight int `json:"weight"`
+}
+
+type GraphTestCase struct {
+	// Nodes is the number of nodes, which are numbered from 0.
+	Nodes  int    `json:"nodes"`
+	Edges  []Edge `json:"edges"`
+	Start  int    `json:"start"`
+	Target int    `json:"target"`
+}
+
+type GraphSolution struct {
+	// Path lists the nodes from start to target, both included.
+	Path []int `json:"path"`
+}
+
+func NewStageShortestPath(opts Options) (StageShortestPath, error) {
+	edgeCases, err := loadEdgeCases("shortest-path", opts, validateGraph)
+	return StageShortestPath{edgeCases: edgeCases}, err
+}
+
+// validateGraph rejects graphs the reference solution cannot solve, as
+// their nodes are out of range or the target cannot be reached.
+func validateGraph(testCase GraphTestCase) error {
+	if testCase.Nodes < 1 {
+		return errors.New("a graph needs at least one node")
+	}
+	inRange := func(node int) bool {
+		return node >= 0 && node < testCase.Nodes
+	}
+	for _, e := range testCase.Edges {
+		if !inRange(e.From) || !inRange(e.To) {
+			return fmt.Errorf("edge from %d to %d leaves the %d nodes", e.From, e.To, testCase.Nodes)
+		}
+		if e.Weight < 1 {
+			return fmt.Errorf("edge from %d to %d has weight %d, must be positive", e.From, e.To, e.Weight)
+		}
+	}
+	if !inRange(testCase.Start) || !inRange(testCase.Target) {
+		return fmt.Errorf("start %d or target %d is not one of the %d nodes", testCase.Start, testCase.Target, testCase.Nodes)
+	}
+	if costs, _ := shortestPaths(testCase); costs[testCase.Target] == math.MaxInt {
+		return fmt.Errorf("target %d cannot be reached from start %d", testCase.Target, testCase.Start)
+	}
+	return nil
+}
+
+func (s StageShortestPath) Describe() Description {
+	example := GraphTestCase{
+		Nodes: 4,
+		Edges: []Edge{
+			{From: 0, To: 1, Weight: 4},
+			{From: 0, To: 2, Weight: 1},
+			{From: 2, To: 1, Weight: 2},
+			{From: 1, To: 3, Weight: 1},
+			{From: 2, To: 3, Weight: 5},
+		},
+		Start:  0,
+		Target: 3,
+	}
+
+	return Description{
+		Title:     "Shortest path",
+		Statement: string(doc("shortest-path.md")),
+		Schemas: Schemas{
+			Testcase: doc("shortest-path.testcase.schema.json"),
+			Solution: doc("shortest-path.solution.schema.json"),
+		},
+		Example: Example{
+			Testcase: example,
+			Solution: solveGraphTestcase(example),
+		},
+	}
+}
+
+type queueItem struct {
+	node int
+	cost int
+}
+
+// costQueue is a min-heap of nodes by their tentative cost.
+type costQueue []queueItem
+
+func (q costQueue) Len() int           { return len(q) }
+func (q costQueue) Less(i, j int) bool { return q[i].cost < q[j].cost }
+func (q costQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *costQueue) Push(x any)        { *q = append(*q, x.(queueItem)) }
+func (q *costQueue) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+// shortestPaths runs Dijkstra's algorithm from start and returns the cost
+// of reaching every node, math.MaxInt if it is unreachable, as well as the
+// predecessor of every node on a cheapest path.
+func shortestPaths(testCase GraphTestCase) ([]int, []int) {
+	outgoing := make([][]Edge, testCase.Nodes)
+	for _, e := range testCase.Edges {
+		outgoing[e.From] = append(outgoing[e.From], e)
+	}
+
+	costs := make([]int, testCase.Nodes)
+	previous := make([]int, testCase.Nodes)
+	for i := range costs {
+		costs[i] = math.MaxInt
+		previous[i] = -1
+	}
+	costs[testCase.Start] = 0
+
+	queue := &costQueue{{node: testCase.Start}}
+	for queue.Len() > 0 {
+		item := heap.Pop(queue).(queueItem)
+		if item.cost > costs[item.node] {
+			continue
+		}
+		for _, e := range outgoing[item.node] {
+			if cost := item.cost + e.Weight; cost < costs[e.To] {
+				costs[e.To] = cost
+				previous[e.To] = item.node
+				heap.Push(queue, queueItem{node: e.To, cost: cost})
+			}
+		}
+	}
+	return costs, previous
+}
+
+func solveGraphTestcase(testCase GraphTestCase) GraphSolution {
+	_, previous := shortestPaths(testCase)
+
+	path := []int{testCase.Target}
+	for node := testCase.Target; node != testCase.Start; {
+		node = previous[node]
+		path = append(path, node)
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+
+	return GraphSolution{
+		Path: path,
+	}
+}
+
+// pathCost returns the cost of following path through the graph, taking
+// the cheapest of parallel edges. It reports false if path is not a path
+// from start to target.
+func pathCost(testCase GraphTestCase, path []int) (int, bool) {
+	if len(path) == 0 || path[0] != testCase.Start || path[len(path)-1] != testCase.Target {
+		return 0, false
+	}
+
+	type hop struct{ from, to int }
+	weights := map[hop]int{}
+	for _, e := range testCase.Edges {
+		h := hop{e.From, e.To}
+		if w, exists := weights[h]; !exists || e.Weight < w {
+			weights[h] = e.Weight
+		}
+	}
+
+	cost := 0
+	for i := 1; i < len(path); i++ {
+		w, exists := weights[hop{path[i-1], path[i]}]
+		if !exists {
+			return 0, false
+		}
+		cost += w
+	}
+	return cost, true
+}
+
+// CreateTestcase generates a graph in which every node can be reached from
+// the start, by chaining all nodes in random order first. The other edges
+// are random and usually cheaper than the chain.
+func (s StageShortestPath) CreateTestcase(token string, nr int) GraphTestCase {
+	testcase, nr, curated := s.pick(token, nr)
+	if curated {
+		return testcase
+	}
+
+	randGen := RandFromTokenAndTestcase(token, nr)
+
+	nodes := 10 * nr * nr
+	order := randGen.Perm(nodes)
+
+	edges := make([]Edge, 0, 4*nodes)
+	for i := 1; i < nodes; i++ {
+		edges = append(edges, Edge{From: order[i-1], To: order[i], Weight: 50 + randGen.Intn(51)})
+	}
+	for len(edges) < cap(edges) {
+		from, to := randGen.Intn(nodes), randGen.Intn(nodes)
+		if from == to {
+			continue
+		}
+		edges = append(edges, Edge{From: from, To: to, Weight: 1 + randGen.Intn(100)})
+	}
+	randGen.Shuffle(len(edges), func(i, j int) {
+		edges[i], edges[j] = edges[j], edges[i]
+	})
+
+	return GraphTestCase{
+		Nodes:  nodes,
+		Edges:  edges,
+		Start:  order[0],
+		Target: order[1+randGen.Intn(nodes-1)],
+	}
+}
+
+func (s StageShortestPath) GetSolution(token string, nr int) GraphSolution {
+	testcase := s.CreateTestcase(token, nr)
+	return solveGraphTestcase(testcase)
+}
+
+// ValidateSolution accepts every path from start to target with the optimal
+// cost, not only the one found by the reference solution.
+func (s StageShortestPath) ValidateSolution(token string, nr int, solution GraphSolution) bool {
+	testcase := s.CreateTestcase(token, nr)
+
+	cost, valid := pathCost(testcase, solution.Path)
+	if !valid {
+		return false
+	}
+	costs, _ := shortestPaths(testcase)
+	return cost == costs[testcase.Target]
+}
@@ -0,0 +1,85 @@
+package stage
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestShortestPathAcceptsEveryOptimalPath(t *testing.T) {
+	// Two paths cost 2. The edge from 3 back to 0 would make 0, 3 cheaper
+	// if it could be taken in reverse.
+	graph := GraphTestCase{
+		Nodes: 4,
+		Edges: []Edge{
+			{From: 0, To: 1, Weight: 1},
+			{From: 1, To: 3, Weight: 1},
+			{From: 0, To: 2, Weight: 1},
+			{From: 2, To: 3, Weight: 1},
+			{From: 3, To: 0, Weight: 1},
+			{From: 2, To: 1, Weight: 7},
+		},
+		Start:  0,
+		Target: 3,
+	}
+	dir := t.TempDir()
+	content, _ := json.Marshal([]edgeCase[GraphTestCase]{{Name: "two optimal paths", Testcase: graph}})
+	if err := os.WriteFile(filepath.Join(dir, "shortest-path.json"), content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewStageShortestPath(Options{CasesDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		path []int
+		want bool
+	}{
+		{"first optimal path", []int{0, 1, 3}, true},
+		{"second optimal path", []int{0, 2, 3}, true},
+		{"reversed edge", []int{0, 3}, false},
+		{"valid but not optimal", []int{0, 2, 1, 3}, false},
+		{"missing edge", []int{0, 1, 2, 3}, false},
+		{"wrong start", []int{1, 3}, false},
+		{"wrong target", []int{0, 1}, false},
+		{"empty", nil, false},
+	}
+
+	for _, tt := range tests {
+		if got := s.ValidateSolution("token", 2, GraphSolution{Path: tt.path}); got != tt.want {
+			t.Errorf("%s %v: ValidateSolution() = %v, want %v", tt.name, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestShortestPathRejectsCheaperInvalidPath(t *testing.T) {
+	s, err := NewStageShortestPath(Options{EdgeCaseInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for nr := 1; nr <= 3; nr++ {
+		testcase := s.CreateTestcase("token", nr)
+		solution := s.GetSolution("token", nr)
+		if !s.ValidateSolution("token", nr, solution) {
+			t.Fatalf("testcase %d: reference path %v rejected", nr, solution.Path)
+		}
+
+		// Jumping from the start straight to the target is cheaper than
+		// every path, but only valid if such an edge exists.
+		shortcut := []int{testcase.Start, testcase.Target}
+		if _, exists := pathCost(testcase, shortcut); !exists && s.ValidateSolution("token", nr, GraphSolution{Path: shortcut}) {
+			t.Errorf("testcase %d: shortcut %v without an edge accepted", nr, shortcut)
+		}
+		// Leaving out a node of the reference path skips an edge.
+		if path := solution.Path; len(path) > 2 {
+			skipped := append([]int{path[0]}, path[2:]...)
+			if _, exists := pathCost(testcase, skipped); !exists && s.ValidateSolution("token", nr, GraphSolution{Path: skipped}) {
+				t.Errorf("testcase %d: path %v skipping a node accepted", nr, skipped)
+			}
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package stage
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// ErrMalformedMove is returned by Mover.Move if the move could not be
+// decoded.
+var ErrMalformedMove = errors.New("malformed move")
+
+// ErrOutOfMoves is returned by Mover.Move once a testcase does not allow any
+// further moves.
+var ErrOutOfMoves = errors.New("out of moves")
+
+// InteractiveStage is a Stage whose testcases take several requests. The
+// stage keeps the state of every token and testcase between the moves M,
+// answering each of them with R.
+type InteractiveStage[T any, S any, M any, R any] interface {
+	Stage[T, S]
+	Move(token string, nr int, move M) (R, error)
+}
+
+// Mover is implemented by the runners of interactive stages.
+type Mover interface {
+	Move(token string, nr int, body io.Reader) (any, error)
+}
+
+type interactiveRunner[T any, S any, M any, R any] struct {
+	runner[T, S]
+	interactive InteractiveStage[T, S, M, R]
+	// moveSchema validates moves like runner.schema validates solutions.
+	moveSchema        *jsonschema.Schema
+	appliedMoveSchema json.RawMessage
+}
+
+// NewInteractiveRunner is NewRunner for interactive stages, the returned
+// Runner implements Mover.
+func NewInteractiveRunner[T any, S any, M any, R any](s InteractiveStage[T, S, M, R], opts Options) (Runner, error) {
+	base, err := newRunner[T, S](s, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	r := interactiveRunner[T, S, M, R]{runner: base, interactive: s}
+	if d, ok := any(s).(Describer); ok {
+		if schema := d.Describe().Schemas.Move; schema != nil {
+			r.moveSchema, r.appliedMoveSchema, err = compileSchema("move.schema.json", schema, opts.Strict)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return r, nil
+}
+
+func (r interactiveRunner[T, S, M, R]) Move(token string, nr int, body io.Reader) (any, error) {
+	encoded, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+
+	move, err := decode[M](r.moveSchema, encoded, ErrMalformedMove)
+	if err != nil {
+		return nil, err
+	}
+	return r.interactive.Move(token, nr, move)
+}
+
+func (r interactiveRunner[T, S, M, R]) Describe() Description {
+	description := r.runner.Describe()
+	if r.appliedMoveSchema != nil {
+		description.Schemas.Move = r.appliedMoveSchema
+	}
+	return description
+}
@@ -0,0 +1,397 @@
+package stage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// StageMaze lets students explore a maze move by move until they find the
+// exit. Only the cell they stand on is revealed with every move.
+type StageMaze struct {
+	edgeCases[maze]
+	// states are dropped once they were not used for Options.StateTTL.
+	states *mazeStates
+}
+
+type Position struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type MazeTestCase struct {
+	Width    int      `json:"width"`
+	Height   int      `json:"height"`
+	Start    Position `json:"start"`
+	MaxMoves int      `json:"maxMoves"`
+	// Open lists the directions without a wall at the start.
+	Open []string `json:"open"`
+}
+
+type MazeMove struct {
+	// Move is north, east, south, west or restart.
+	Move string `json:"move"`
+}
+
+// MazeView is what the student sees after a move.
+type MazeView struct {
+	Position  Position `json:"position"`
+	Moved     bool     `json:"moved"`
+	Open      []string `json:"open"`
+	Exit      bool     `json:"exit"`
+	Moves     int      `json:"moves"`
+	MovesLeft int      `json:"movesLeft"`
+}
+
+type MazeSolution struct {
+	Exit Position `json:"exit"`
+}
+
+type direction struct {
+	name   string
+	dx, dy int
+}
+
+// directions index the wall bits of a cell, y grows to the south.
+var directions = []direction{
+	{name: "north", dx: 0, dy: -1},
+	{name: "east", dx: 1, dy: 0},
+	{name: "south", dx: 0, dy: 1},
+	{name: "west", dx: -1, dy: 0},
+}
+
+type maze struct {
+	width, height int
+	// open has a bit per direction without a wall for every cell.
+	open     []uint8
+	exit     Position
+	maxMoves int
+}
+
+func (m maze) contains(p Position) bool {
+	return p.X >= 0 && p.X < m.width && p.Y >= 0 && p.Y < m.height
+}
+
+func (m maze) index(p Position) int {
+	return p.Y*m.width + p.X
+}
+
+func (m maze) openDirections(p Position) []string {
+	open := make([]string, 0, len(directions))
+	for i, d := range directions {
+		if m.open[m.index(p)]&(1<<i) != 0 {
+			open = append(open, d.name)
+		}
+	}
+	return open
+}
+
+// generateMaze carves a perfect maze, which has exactly one path between
+// any two cells, with a randomized depth-first search from the start.
+func generateMaze(token string, nr int) maze {
+	randGen := RandFromTokenAndTestcase(token, nr)
+
+	size := 2 + nr
+	m := maze{
+		width:  size,
+		height: size,
+		open:   make([]uint8, size*size),
+	}
+
+	visited := make([]bool, size*size)
+	visited[0] = true
+	stack := []Position{{}}
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+
+		var candidates []int
+		for i, d := range directions {
+			next := Position{X: current.X + d.dx, Y: current.Y + d.dy}
+			if m.contains(next) && !visited[m.index(next)] {
+				candidates = append(candidates, i)
+			}
+		}
+		if len(candidates) == 0 {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+
+		i := candidates[randGen.Intn(len(candidates))]
+		next := Position{X: current.X + directions[i].dx, Y: current.Y + directions[i].dy}
+		m.open[m.index(current)] |= 1 << i
+		m.open[m.index(next)] |= 1 << ((i + 2) % len(directions))
+		visited[m.index(next)] = true
+		stack = append(stack, next)
+	}
+
+	exit := 1 + randGen.Intn(size*size-1)
+	m.exit = Position{X: exit % size, Y: exit / size}
+	m.maxMoves = m.moveBudget()
+	return m
+}
+
+// passages counts the openings between two cells.
+func (m maze) passages() int {
+	passages := 0
+	for _, open := range m.open {
+		for i := range directions {
+			if open&(1<<i) != 0 {
+				passages++
+			}
+		}
+	}
+	return passages / 2
+}
+
+// moveBudget is the number of moves a testcase allows, two per passage but
+// at least two per cell. A depth-first search remembering the visited
+// positions needs at most two moves per cell, as it never takes a passage
+// to a known cell. The extra moves for the passages of loops in curated
+// mazes leave room for searches taking them anyway.
+func (m maze) moveBudget() int {
+	return 2 * max(m.passages(), m.width*m.height)
+}
+
+// UnmarshalJSON reads a curated maze drawn as lines of text. Lines of walls
+// alternate with lines of cells, which alternate with the walls between
+// them. A space is a cell or a passage, any other character a wall, and
+// the cell marked E is the exit:
+//
+//	+-+-+
+//	|   |
+//	+-+ +
+//	|E  |
+//	+-+-+
+//
+// The start is the top left cell. Unlike generated mazes, curated ones may
+// have loops.
+func (m *maze) UnmarshalJSON(data []byte) error {
+	var lines []string
+	if err := json.Unmarshal(data, &lines); err != nil {
+		return err
+	}
+	if len(lines) < 3 || len(lines)%2 == 0 || len(lines[0]) < 3 || len(lines[0])%2 == 0 {
+		return errors.New("maze: needs an odd number of at least 3 lines of an odd length of at least 3")
+	}
+
+	width, height := len(lines[0])/2, len(lines)/2
+	*m = maze{
+		width:  width,
+		height: height,
+		open:   make([]uint8, width*height),
+	}
+	exits := 0
+	for y, line := range lines {
+		if len(line) != 2*width+1 {
+			return fmt.Errorf("maze: line %d has %d characters, want %d", y+1, len(line), 2*width+1)
+		}
+		for x := range line {
+			border := x == 0 || y == 0 || x == 2*width || y == 2*height
+			switch {
+			case x%2 == 1 && y%2 == 1:
+				if line[x] == 'E' {
+					m.exit = Position{X: x / 2, Y: y / 2}
+					exits++
+				} else if line[x] != ' ' {
+					return fmt.Errorf("maze: line %d column %d is a cell, which has to be a space or E", y+1, x+1)
+				}
+			case line[x] != ' ':
+			case border:
+				return fmt.Errorf("maze: line %d column %d leaves the maze", y+1, x+1)
+			case x%2 == 0 && y%2 == 1:
+				// A passage between the cells west and east of it.
+				m.open[m.index(Position{X: x/2 - 1, Y: y / 2})] |= 1 << 1
+				m.open[m.index(Position{X: x / 2, Y: y / 2})] |= 1 << 3
+			case x%2 == 1 && y%2 == 0:
+				// A passage between the cells north and south of it.
+				m.open[m.index(Position{X: x / 2, Y: y/2 - 1})] |= 1 << 2
+				m.open[m.index(Position{X: x / 2, Y: y / 2})] |= 1 << 0
+			default:
+				return fmt.Errorf("maze: line %d column %d is a corner, which has to be a wall", y+1, x+1)
+			}
+		}
+	}
+	if exits != 1 || m.exit == (Position{}) {
+		return errors.New("maze: needs exactly one exit E, which is not the start")
+	}
+	if !m.reachable(m.exit) {
+		return fmt.Errorf("maze: the exit at %d,%d cannot be reached from the start", m.exit.X, m.exit.Y)
+	}
+	m.maxMoves = m.moveBudget()
+	return nil
+}
+
+// reachable reports whether there is a path from the start to p.
+func (m maze) reachable(p Position) bool {
+	visited := make([]bool, m.width*m.height)
+	visited[0] = true
+	stack := []Position{{}}
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if current == p {
+			return true
+		}
+		for i, d := range directions {
+			next := Position{X: current.X + d.dx, Y: current.Y + d.dy}
+			if m.open[m.index(current)]&(1<<i) != 0 && !visited[m.index(next)] {
+				visited[m.index(next)] = true
+				stack = append(stack, next)
+			}
+		}
+	}
+	return false
+}
+
+// layout returns the curated or generated maze of testcase nr.
+func (s StageMaze) layout(token string, nr int) maze {
+	m, nr, curated := s.pick(token, nr)
+	if curated {
+		return m
+	}
+	return generateMaze(token, nr)
+}
+
+type mazeKey struct {
+	token string
+	nr    int
+}
+
+type mazeState struct {
+	position Position
+	moves    int
+	// found is set once the exit has been reached, restarts keep it.
+	found bool
+	// used is the time of the last move.
+	used time.Time
+}
+
+type mazeStates struct {
+	mu     sync.Mutex
+	states map[mazeKey]*mazeState
+	// ttl is how long a state is kept after its last move, forever if it
+	// is zero.
+	ttl   time.Duration
+	swept time.Time
+}
+
+// use returns the state of key, creating it if there is none, and records
+// a move at now. Every ttl it drops the states that have not been used for
+// longer than ttl, so finding them takes constant time on average.
+// The caller has to hold mu.
+func (ms *mazeStates) use(key mazeKey, now time.Time) *mazeState {
+	if ms.ttl > 0 && now.Sub(ms.swept) >= ms.ttl {
+		for k, state := range ms.states {
+			if now.Sub(state.used) > ms.ttl {
+				delete(ms.states, k)
+			}
+		}
+		ms.swept = now
+	}
+
+	state, exists := ms.states[key]
+	if !exists {
+		state = &mazeState{}
+		ms.states[key] = state
+	}
+	state.used = now
+	return state
+}
+
+func NewStageMaze(opts Options) (StageMaze, error) {
+	edgeCases, err := loadEdgeCases[maze]("maze", opts, nil)
+	return StageMaze{
+		edgeCases: edgeCases,
+		states:    &mazeStates{states: map[mazeKey]*mazeState{}, ttl: opts.StateTTL},
+	}, err
+}
+
+func (s StageMaze) Describe() Description {
+	return Description{
+		Title:     "Maze",
+		Statement: string(doc("maze.md")),
+		Schemas: Schemas{
+			Testcase: doc("maze.testcase.schema.json"),
+			Solution: doc("maze.solution.schema.json"),
+			Move:     doc("maze.move.schema.json"),
+		},
+		Example: Example{
+			Testcase: s.CreateTestcase("example", 1),
+			Solution: s.GetSolution("example", 1),
+		},
+	}
+}
+
+func (s StageMaze) CreateTestcase(token string, nr int) MazeTestCase {
+	m := s.layout(token, nr)
+	return MazeTestCase{
+		Width:    m.width,
+		Height:   m.height,
+		MaxMoves: m.maxMoves,
+		Open:     m.openDirections(Position{}),
+	}
+}
+
+func (s StageMaze) Move(token string, nr int, move MazeMove) (MazeView, error) {
+	m := s.layout(token, nr)
+
+	s.states.mu.Lock()
+	defer s.states.mu.Unlock()
+
+	state := s.states.use(mazeKey{token: token, nr: nr}, time.Now())
+
+	moved := false
+	if move.Move == "restart" {
+		state.position = Position{}
+		state.moves = 0
+	} else {
+		i := -1
+		for j, d := range directions {
+			if d.name == move.Move {
+				i = j
+			}
+		}
+		if i < 0 {
+			return MazeView{}, fmt.Errorf("%w: unknown move %q", ErrMalformedMove, move.Move)
+		}
+		if state.moves >= m.maxMoves {
+			return MazeView{}, fmt.Errorf("%w: all %d moves are used up, restart to try again", ErrOutOfMoves, m.maxMoves)
+		}
+
+		state.moves++
+		if m.open[m.index(state.position)]&(1<<i) != 0 {
+			state.position.X += directions[i].dx
+			state.position.Y += directions[i].dy
+			moved = true
+		}
+		if state.position == m.exit {
+			state.found = true
+		}
+	}
+
+	return MazeView{
+		Position:  state.position,
+		Moved:     moved,
+		Open:      m.openDirections(state.position),
+		Exit:      state.position == m.exit,
+		Moves:     state.moves,
+		MovesLeft: m.maxMoves - state.moves,
+	}, nil
+}
+
+func (s StageMaze) GetSolution(token string, nr int) MazeSolution {
+	return MazeSolution{Exit: s.layout(token, nr).exit}
+}
+
+// ValidateSolution only accepts the exit once it has been reached by moves,
+// guessing it is not enough.
+func (s StageMaze) ValidateSolution(token string, nr int, solution MazeSolution) bool {
+	s.states.mu.Lock()
+	state, exists := s.states.states[mazeKey{token: token, nr: nr}]
+	found := exists && state.found
+	s.states.mu.Unlock()
+
+	return found && solution == s.GetSolution(token, nr)
+}
@@ -0,0 +1,127 @@
+package stage
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMazeStatesExpire(t *testing.T) {
+	states := &mazeStates{states: map[mazeKey]*mazeState{}, ttl: time.Minute}
+	start := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	at := func(seconds int) time.Time {
+		return start.Add(time.Duration(seconds) * time.Second)
+	}
+	idle, active := mazeKey{token: "idle", nr: 1}, mazeKey{token: "active", nr: 1}
+
+	states.use(idle, at(0)).moves = 3
+	if state := states.use(idle, at(55)); state.moves != 3 {
+		t.Errorf("state used within the ttl was reset to %d moves", state.moves)
+	}
+	states.use(active, at(70)).moves = 5
+
+	states.use(mazeKey{token: "other", nr: 1}, at(130))
+	if _, exists := states.states[idle]; exists {
+		t.Error("state unused for longer than the ttl was kept")
+	}
+	if state, exists := states.states[active]; !exists || state.moves != 5 {
+		t.Error("state used within the ttl was dropped")
+	}
+}
+
+// newTestMaze serves the drawn maze as testcase 2 of a maze stage.
+func newTestMaze(t *testing.T, drawing string) (StageMaze, maze) {
+	t.Helper()
+
+	var m maze
+	if err := json.Unmarshal([]byte(drawing), &m); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewStageMaze(Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.edgeCases = edgeCases[maze]{curated: []maze{m}, interval: 1}
+	return s, m
+}
+
+func mustMove(t *testing.T, s StageMaze, token string, move string) MazeView {
+	t.Helper()
+
+	view, err := s.Move(token, 2, MazeMove{Move: move})
+	if err != nil {
+		t.Fatalf("move %s: %v", move, err)
+	}
+	return view
+}
+
+func TestMazeMoves(t *testing.T) {
+	// The exit is south of the start.
+	s, m := newTestMaze(t, `["+-+-+", "|   |", "+ +-+", "|E  |", "+-+-+"]`)
+
+	if view := mustMove(t, s, "token", "north"); view.Moved || view.Position != (Position{}) || view.Moves != 1 {
+		t.Errorf("bumping into a wall = %+v, want to stay at the start after 1 move", view)
+	}
+	if view := mustMove(t, s, "token", "east"); !view.Moved || view.Position != (Position{X: 1}) || view.MovesLeft != m.maxMoves-2 {
+		t.Errorf("moving east = %+v, want to be at {1 0} with %d moves left", view, m.maxMoves-2)
+	}
+	if _, err := s.Move("token", 2, MazeMove{Move: "up"}); !errors.Is(err, ErrMalformedMove) {
+		t.Errorf("unknown move: %v, want ErrMalformedMove", err)
+	}
+
+	if s.ValidateSolution("token", 2, MazeSolution{Exit: m.exit}) {
+		t.Error("exit accepted before it was reached")
+	}
+	mustMove(t, s, "token", "west")
+	if view := mustMove(t, s, "token", "south"); !view.Exit {
+		t.Errorf("moving to the exit = %+v, want the exit", view)
+	}
+	if s.ValidateSolution("token", 2, MazeSolution{Exit: Position{X: 1, Y: 1}}) {
+		t.Error("wrong exit accepted")
+	}
+
+	if view := mustMove(t, s, "token", "restart"); view.Position != (Position{}) || view.Moves != 0 {
+		t.Errorf("restart = %+v, want to be at the start without moves", view)
+	}
+	if !s.ValidateSolution("token", 2, MazeSolution{Exit: m.exit}) {
+		t.Error("exit rejected after a restart")
+	}
+	if s.ValidateSolution("other", 2, MazeSolution{Exit: m.exit}) {
+		t.Error("exit accepted for a token that did not reach it")
+	}
+}
+
+func TestMazeRunsOutOfMoves(t *testing.T) {
+	s, m := newTestMaze(t, `["+-+-+", "|   |", "+ +-+", "|E  |", "+-+-+"]`)
+
+	for range m.maxMoves {
+		mustMove(t, s, "token", "north")
+	}
+	if _, err := s.Move("token", 2, MazeMove{Move: "south"}); !errors.Is(err, ErrOutOfMoves) {
+		t.Fatalf("move after %d moves: %v, want ErrOutOfMoves", m.maxMoves, err)
+	}
+
+	mustMove(t, s, "token", "restart")
+	if view := mustMove(t, s, "token", "south"); !view.Exit {
+		t.Errorf("move after a restart = %+v, want the exit", view)
+	}
+}
+
+func TestMazeMoveBudget(t *testing.T) {
+	var room maze
+	// 3x3 cells without inner walls have 12 passages.
+	if err := json.Unmarshal([]byte(`["+-+-+-+", "|     |", "+ + + +", "|     |", "+ + + +", "|    E|", "+-+-+-+"]`), &room); err != nil {
+		t.Fatal(err)
+	}
+	if room.maxMoves != 24 {
+		t.Errorf("room allows %d moves, want 24", room.maxMoves)
+	}
+
+	for nr := 1; nr <= 5; nr++ {
+		m := generateMaze("token", nr)
+		if cells := m.width * m.height; m.passages() != cells-1 || m.maxMoves != 2*cells {
+			t.Errorf("testcase %d has %d passages and allows %d moves, want %d and %d", nr, m.passages(), m.maxMoves, cells-1, 2*cells)
+		}
+	}
+}
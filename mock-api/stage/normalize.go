@@ -0,0 +1,63 @@
+package stage
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Normalization declares which differences between a submitted and the
+// expected text are ignored when validating a solution.
+type Normalization struct {
+	// TrimSpace ignores leading and trailing white space.
+	TrimSpace bool `json:"trimSpace,omitempty" yaml:"trimSpace"`
+	// CollapseSpace treats every run of white space like a single space and
+	// implies TrimSpace.
+	CollapseSpace bool `json:"collapseSpace,omitempty" yaml:"collapseSpace"`
+	// IgnoreCase compares case-insensitively.
+	IgnoreCase bool `json:"ignoreCase,omitempty" yaml:"ignoreCase"`
+	// IgnoreOrder compares lists of texts regardless of their order.
+	IgnoreOrder bool `json:"ignoreOrder,omitempty" yaml:"ignoreOrder"`
+}
+
+// IsZero reports whether n ignores no differences at all.
+func (n Normalization) IsZero() bool {
+	return n == Normalization{}
+}
+
+func (n Normalization) Apply(s string) string {
+	if n.CollapseSpace {
+		s = strings.Join(strings.FieldsFunc(s, unicode.IsSpace), " ")
+	} else if n.TrimSpace {
+		s = strings.TrimSpace(s)
+	}
+	if n.IgnoreCase {
+		s = strings.ToLower(s)
+	}
+	return s
+}
+
+// Equal compares two texts after normalizing them.
+func (n Normalization) Equal(a, b string) bool {
+	return n.Apply(a) == n.Apply(b)
+}
+
+// EqualLists compares two lists of texts after normalizing every entry.
+// Duplicates are significant, also when the order is ignored.
+func (n Normalization) EqualLists(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	normalize := func(list []string) []string {
+		normalized := make([]string, len(list))
+		for i, s := range list {
+			normalized[i] = n.Apply(s)
+		}
+		if n.IgnoreOrder {
+			slices.Sort(normalized)
+		}
+		return normalized
+	}
+	return slices.Equal(normalize(a), normalize(b))
+}
@@ -0,0 +1,90 @@
+package stage
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNormalizationApply(t *testing.T) {
+	tests := []struct {
+		name string
+		n    Normalization
+		in   string
+		want string
+	}{
+		{"nothing", Normalization{}, "  A  b\n", "  A  b\n"},
+		{"trim", Normalization{TrimSpace: true}, " \tA  b\n", "A  b"},
+		{"collapse implies trim", Normalization{CollapseSpace: true}, " \tA \n b\n", "A b"},
+		{"collapse with trim", Normalization{TrimSpace: true, CollapseSpace: true}, "A\t\tb", "A b"},
+		{"collapse unicode space", Normalization{CollapseSpace: true}, "A  b", "A b"},
+		{"ignore case", Normalization{IgnoreCase: true}, " ÄbC ", " äbc "},
+		{"all", Normalization{CollapseSpace: true, IgnoreCase: true}, " The  STACK\n", "the stack"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.n.Apply(tt.in); got != tt.want {
+			t.Errorf("%s: Apply(%q) = %q, want %q", tt.name, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizationEqualLists(t *testing.T) {
+	tests := []struct {
+		name string
+		n    Normalization
+		a, b []string
+		want bool
+	}{
+		{"same", Normalization{}, []string{"a", "b"}, []string{"a", "b"}, true},
+		{"order matters", Normalization{}, []string{"a", "b"}, []string{"b", "a"}, false},
+		{"order ignored", Normalization{IgnoreOrder: true}, []string{"a", "b"}, []string{"b", "a"}, true},
+		{"duplicates count", Normalization{IgnoreOrder: true}, []string{"a", "a", "b"}, []string{"a", "b", "b"}, false},
+		{"duplicates in order", Normalization{IgnoreOrder: true}, []string{"a", "b", "a"}, []string{"a", "a", "b"}, true},
+		{"missing duplicate", Normalization{IgnoreOrder: true}, []string{"a", "a"}, []string{"a"}, false},
+		{"entries normalized", Normalization{TrimSpace: true, IgnoreCase: true, IgnoreOrder: true}, []string{" Stack", "TREE "}, []string{"tree", "stack"}, true},
+		{"normalized duplicates", Normalization{IgnoreCase: true, IgnoreOrder: true}, []string{"A", "a"}, []string{"a", "b"}, false},
+		{"both empty", Normalization{}, nil, []string{}, true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.n.EqualLists(tt.a, tt.b); got != tt.want {
+			t.Errorf("%s: EqualLists(%q, %q) = %v, want %v", tt.name, tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestStageTextNormalizesSolutions(t *testing.T) {
+	s, err := NewStageText(Options{EdgeCaseInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const nr = 3
+	reference := s.GetSolution("token", nr)
+	reversed := make([]string, len(reference.Words))
+	for i, word := range reference.Words {
+		reversed[len(reversed)-1-i] = " " + strings.ToUpper(word)
+	}
+
+	tests := []struct {
+		name     string
+		solution TextSolution
+		want     bool
+	}{
+		{"reference", reference, true},
+		{"spaced and cased differently", TextSolution{
+			Plaintext: "  " + strings.ToUpper(strings.Join(strings.Fields(reference.Plaintext), "\n\t ")) + "\n",
+			Words:     reversed,
+		}, true},
+		{"other text", TextSolution{Plaintext: reference.Plaintext + " more", Words: reference.Words}, false},
+		{"joined words", TextSolution{Plaintext: strings.Join(strings.Fields(reference.Plaintext), ""), Words: reference.Words}, false},
+		{"missing word", TextSolution{Plaintext: reference.Plaintext, Words: reference.Words[1:]}, false},
+		{"duplicate word", TextSolution{Plaintext: reference.Plaintext, Words: append(slices.Clone(reference.Words[1:]), reference.Words[1])}, false},
+	}
+
+	for _, tt := range tests {
+		if got := s.ValidateSolution("token", nr, tt.solution); got != tt.want {
+			t.Errorf("%s: ValidateSolution() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 )
 
 type StagePointsC struct {
+	edgeCases[TestCase]
 }
 
 type Point struct {
@@ -28,27 +29,84 @@ type Solution struct {
 	AccessiblePoints []Point `json:"accessiblePoints"`
 }
 
-func (p Point) Atan2() float64 {
-	return math.Atan2(p.Y, p.X)
+// cross is the z component of the cross product of p and q, positive if q
+// lies counter-clockwise of p.
+func (p Point) cross(q Point) float64 {
+	return p.X*q.Y - p.Y*q.X
 }
 
-func NewStagePointC() StagePointsC {
-	return StagePointsC{}
+func (p Point) dot(q Point) float64 {
+	return p.X*q.X + p.Y*q.Y
 }
 
-func solveTestcase(testCase TestCase) Solution {
-	atanA := testCase.Obstacle.PointA.Atan2()
-	atanB := testCase.Obstacle.PointB.Atan2()
+// inCone reports whether p lies in the closed cone spanned by the rays from
+// the origin through a and b, the smaller of the two angles between them.
+// Unlike comparing angles from math.Atan2 this has no discontinuity at ±π.
+func inCone(p, a, b Point) bool {
+	turn := a.cross(b)
+	switch {
+	case turn < 0:
+		a, b = b, a
+	case turn == 0:
+		// a and b are collinear, the cone is a single ray. If they point in
+		// opposite directions it is ambiguous and hides nothing.
+		return a.dot(b) > 0 && a.cross(p) == 0 && a.dot(p) >= 0
+	}
+	return a.cross(p) >= 0 && p.cross(b) >= 0
+}
 
-	min, max := math.Min(atanA, atanB), math.Max(atanB, atanA)
-	absLine := math.Abs(testCase.Obstacle.Line)
+// behind reports whether p is strictly further away from the origin than
+// the line y = line, on its side of the x-axis.
+func behind(p Point, line float64) bool {
+	switch {
+	case line > 0:
+		return p.Y > line
+	case line < 0:
+		return p.Y < line
+	}
+	return false
+}
 
-	accessiblePoints := make([]Point, 0)
+func NewStagePointC(opts Options) (StagePointsC, error) {
+	edgeCases, err := loadEdgeCases[TestCase]("points-c", opts, nil)
+	return StagePointsC{edgeCases: edgeCases}, err
+}
 
-	for _, el := range testCase.Targets {
-		elAtan := math.Atan2(el.Y, el.X)
-		if elAtan < min || elAtan > max || math.Abs(el.Y) < absLine {
-			accessiblePoints = append(accessiblePoints, el)
+func (s StagePointsC) Describe() Description {
+	example := TestCase{
+		Obstacle: Obstacle{
+			Line:   2,
+			PointA: Point{X: -2, Y: 4},
+			PointB: Point{X: 2, Y: 4},
+		},
+		Targets: []Point{{X: 0, Y: 5}, {X: 0, Y: 1}, {X: 5, Y: 5}, {X: 1, Y: -5}, {X: -1, Y: 3}},
+	}
+
+	return Description{
+		Title:     "Points C",
+		Statement: string(doc("points-c.md")),
+		Schemas: Schemas{
+			Testcase: doc("points-c.testcase.schema.json"),
+			Solution: doc("points-c.solution.schema.json"),
+		},
+		Example: Example{
+			Testcase: example,
+			Solution: solveTestcase(example),
+		},
+	}
+}
+
+// solveTestcase returns the targets not hidden by the obstacle. A target is
+// hidden if it lies within the cone of the obstacle's end points and
+// strictly behind its line, then the line of sight from the origin crosses
+// the part of the line covered by the obstacle.
+func solveTestcase(testCase TestCase) Solution {
+	obstacle := testCase.Obstacle
+
+	accessiblePoints := make([]Point, 0)
+	for _, target := range testCase.Targets {
+		if !behind(target, obstacle.Line) || !inCone(target, obstacle.PointA, obstacle.PointB) {
+			accessiblePoints = append(accessiblePoints, target)
 		}
 	}
 
@@ -58,6 +116,11 @@ func solveTestcase(testCase TestCase) Solution {
 }
 
 func (s StagePointsC) CreateTestcase(token string, nr int) TestCase {
+	testcase, nr, curated := s.pick(token, nr)
+	if curated {
+		return testcase
+	}
+
 	randGen := RandFromTokenAndTestcase(token, nr)
 	nF := math.Pow(3, float64(nr))
 	n := int(nF)
@@ -104,24 +167,29 @@ func (s StagePointsC) GetSolution(token string, nr int) Solution {
 func (s StagePointsC) ValidateSolution(token string, nr int, solution Solution) bool {
 	validSolution := s.GetSolution(token, nr)
 
-	if len(solution.AccessiblePoints) != len(validSolution.AccessiblePoints) {
+	return samePoints(solution.AccessiblePoints, validSolution.AccessiblePoints)
+}
+
+// samePoints reports whether a and b contain the same points regardless of
+// their order. Both slices get sorted.
+func samePoints(a, b []Point) bool {
+	if len(a) != len(b) {
 		return false
 	}
 
-	sort.Slice(solution.AccessiblePoints, func(i, j int) bool {
-		a, b := solution.AccessiblePoints[i], solution.AccessiblePoints[j]
-		return a.X < b.X || (a.X == b.X && a.Y <= b.Y)
-	})
-
-	sort.Slice(validSolution.AccessiblePoints, func(i, j int) bool {
-		a, b := validSolution.AccessiblePoints[i], validSolution.AccessiblePoints[j]
-		return a.X < b.X || (a.X == b.X && a.Y <= b.Y)
-	})
-
-	for i, el := range validSolution.AccessiblePoints {
-		if el != solution.AccessiblePoints[i] {
+	byCoordinates := func(points []Point) func(i, j int) bool {
+		return func(i, j int) bool {
+			p, q := points[i], points[j]
+			return p.X < q.X || (p.X == q.X && p.Y < q.Y)
 		}
 	}
+	sort.Slice(a, byCoordinates(a))
+	sort.Slice(b, byCoordinates(b))
 
-	return false
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
 }
@@ -0,0 +1,205 @@
+package stage
+
+import (
+	"fmt"
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+// farAway is beyond every crossing with the wall line of the tested
+// obstacles, it stands in for the end of a wall reaching to infinity.
+const farAway = 1e9
+
+type ratPoint struct {
+	x, y *big.Rat
+}
+
+func exact(p Point) ratPoint {
+	return ratPoint{new(big.Rat).SetFloat64(p.X), new(big.Rat).SetFloat64(p.Y)}
+}
+
+// ratOrientation is the sign of the cross product of b-a and c-a.
+func ratOrientation(a, b, c ratPoint) int {
+	abx := new(big.Rat).Sub(b.x, a.x)
+	aby := new(big.Rat).Sub(b.y, a.y)
+	acx := new(big.Rat).Sub(c.x, a.x)
+	acy := new(big.Rat).Sub(c.y, a.y)
+	return new(big.Rat).Sub(new(big.Rat).Mul(abx, acy), new(big.Rat).Mul(aby, acx)).Sign()
+}
+
+// ratOnSegment reports whether c, collinear with a and b, lies between them.
+func ratOnSegment(a, b, c ratPoint) bool {
+	between := func(p, q, r *big.Rat) bool {
+		if p.Cmp(q) > 0 {
+			p, q = q, p
+		}
+		return p.Cmp(r) <= 0 && r.Cmp(q) <= 0
+	}
+	return between(a.x, b.x, c.x) && between(a.y, b.y, c.y)
+}
+
+// segmentsIntersectExact reports whether the closed segments ab and cd
+// share a point, computed exactly.
+func segmentsIntersectExact(a, b, c, d ratPoint) bool {
+	o1, o2 := ratOrientation(a, b, c), ratOrientation(a, b, d)
+	o3, o4 := ratOrientation(c, d, a), ratOrientation(c, d, b)
+	if o1*o2 < 0 && o3*o4 < 0 {
+		return true
+	}
+	return (o1 == 0 && ratOnSegment(a, b, c)) || (o2 == 0 && ratOnSegment(a, b, d)) ||
+		(o3 == 0 && ratOnSegment(c, d, a)) || (o4 == 0 && ratOnSegment(c, d, b))
+}
+
+// wallEnd is where the ray from the origin through e meets the wall line.
+// A ray missing the line, as it runs along or away from the x-axis, leaves
+// the wall open towards its side of the y-axis.
+func wallEnd(e Point, line float64) ratPoint {
+	if e.Y != 0 && (e.Y > 0) == (line > 0) {
+		l := new(big.Rat).SetFloat64(line)
+		x := new(big.Rat).Quo(new(big.Rat).Mul(new(big.Rat).SetFloat64(e.X), l), new(big.Rat).SetFloat64(e.Y))
+		return ratPoint{x, l}
+	}
+	x := farAway
+	if e.X < 0 {
+		x = -farAway
+	}
+	return exact(Point{X: x, Y: line})
+}
+
+// oracle solves a testcase by brute force: a target is hidden if it lies
+// strictly behind the wall line and the segment from the origin to it
+// crosses the wall.
+func oracle(testcase TestCase) []Point {
+	obstacle := testcase.Obstacle
+	origin := exact(Point{})
+	wallA := wallEnd(obstacle.PointA, obstacle.Line)
+	wallB := wallEnd(obstacle.PointB, obstacle.Line)
+
+	visible := []Point{}
+	for _, target := range testcase.Targets {
+		behindLine := obstacle.Line != 0 && (target.Y > 0) == (obstacle.Line > 0) &&
+			new(big.Rat).Abs(exact(target).y).Cmp(new(big.Rat).Abs(new(big.Rat).SetFloat64(obstacle.Line))) > 0
+		if behindLine && segmentsIntersectExact(origin, exact(target), wallA, wallB) {
+			continue
+		}
+		visible = append(visible, target)
+	}
+	return visible
+}
+
+func checkAgainstOracle(t *testing.T, name string, testcase TestCase) {
+	t.Helper()
+
+	got := solveTestcase(testcase).AccessiblePoints
+	want := oracle(testcase)
+	if !samePoints(got, want) {
+		t.Errorf("%s: obstacle %+v\n got %v\nwant %v", name, testcase.Obstacle, got, want)
+	}
+}
+
+func randInt(r *rand.Rand, min, max int) float64 {
+	return float64(min + r.Intn(max-min+1))
+}
+
+// nonZero returns a random integer in [1, max] with the sign of sign.
+func nonZero(r *rand.Rand, max int, sign float64) float64 {
+	v := randInt(r, 1, max)
+	if sign < 0 {
+		return -v
+	}
+	return v
+}
+
+// randomTargets places targets anywhere, on the rays through the end points
+// and on the wall line, all at integer coordinates so the solver computes
+// exactly.
+func randomTargets(r *rand.Rand, obstacle Obstacle) []Point {
+	var targets []Point
+	for range 40 {
+		targets = append(targets, Point{X: randInt(r, -60, 60), Y: randInt(r, -60, 60)})
+	}
+	for k := 1.0; k <= 4; k++ {
+		targets = append(targets,
+			Point{X: k * obstacle.PointA.X, Y: k * obstacle.PointA.Y},
+			Point{X: k * obstacle.PointB.X, Y: k * obstacle.PointB.Y},
+			Point{X: randInt(r, -60, 60), Y: obstacle.Line},
+		)
+	}
+	return targets
+}
+
+func TestSolveTestcaseMatchesOracle(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	for i := range 2000 {
+		line := nonZero(r, 20, randInt(r, -1, 0)*2+1)
+		obstacle := Obstacle{Line: line}
+		switch i % 3 {
+		case 0, 1:
+			// Both end points on the side of the wall, as generated.
+			obstacle.PointA = Point{X: randInt(r, -30, 30), Y: nonZero(r, 30, line)}
+			obstacle.PointB = Point{X: randInt(r, -30, 30), Y: nonZero(r, 30, line)}
+		case 2:
+			// End points on both sides of the x-axis, in the same half of
+			// the plane, the cone contains either the positive or the
+			// negative x-axis. The latter is where atan2 jumps from π to -π.
+			side := randInt(r, -1, 0)*2 + 1
+			obstacle.PointA = Point{X: nonZero(r, 30, side), Y: nonZero(r, 30, 1)}
+			obstacle.PointB = Point{X: nonZero(r, 30, side), Y: nonZero(r, 30, -1)}
+		}
+		if i%2 == 0 {
+			obstacle.PointA, obstacle.PointB = obstacle.PointB, obstacle.PointA
+		}
+
+		checkAgainstOracle(t, fmt.Sprintf("random %d", i), TestCase{
+			Obstacle: obstacle,
+			Targets:  randomTargets(r, obstacle),
+		})
+	}
+}
+
+func TestSolveTestcaseEdgeCases(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+
+	obstacles := map[string]Obstacle{
+		"straddling the negative x-axis":   {Line: -1, PointA: Point{X: -5, Y: 1}, PointB: Point{X: -5, Y: -1}},
+		"straddling the positive x-axis":   {Line: 2, PointA: Point{X: 5, Y: -1}, PointB: Point{X: 5, Y: 3}},
+		"end point on the negative x-axis": {Line: 3, PointA: Point{X: -4, Y: 0}, PointB: Point{X: -1, Y: 5}},
+		"collinear end points":             {Line: 1, PointA: Point{X: 1, Y: 2}, PointB: Point{X: 2, Y: 4}},
+		"equal end points":                 {Line: -2, PointA: Point{X: -3, Y: -3}, PointB: Point{X: -3, Y: -3}},
+		"collinear end points below":       {Line: -1, PointA: Point{X: -2, Y: -6}, PointB: Point{X: -1, Y: -3}},
+		"vertical end points":              {Line: 4, PointA: Point{X: 0, Y: 5}, PointB: Point{X: 0, Y: 8}},
+	}
+	for name, obstacle := range obstacles {
+		checkAgainstOracle(t, name, TestCase{Obstacle: obstacle, Targets: randomTargets(r, obstacle)})
+	}
+}
+
+func TestStagePointsCTestcasesMatchOracle(t *testing.T) {
+	s, err := NewStagePointC(Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, token := range []string{"a", "b", "c"} {
+		for nr := 1; nr <= 8; nr++ {
+			checkAgainstOracle(t, fmt.Sprintf("token %s testcase %d", token, nr), s.CreateTestcase(token, nr))
+		}
+	}
+}
+
+func TestStraddlingObstacle(t *testing.T) {
+	// The wall covers y = -1 from x = -5 towards negative infinity.
+	testcase := TestCase{
+		Obstacle: Obstacle{Line: -1, PointA: Point{X: -5, Y: 1}, PointB: Point{X: -5, Y: -1}},
+		Targets: []Point{
+			{X: -10, Y: -1.5}, {X: -100, Y: -19.9},
+			{X: -10, Y: -1}, {X: -10, Y: 1.5}, {X: 10, Y: -1.5}, {X: 0, Y: -5}, {X: -10, Y: -3},
+		},
+	}
+	want := []Point{{X: -10, Y: -1}, {X: -10, Y: 1.5}, {X: 10, Y: -1.5}, {X: 0, Y: -5}, {X: -10, Y: -3}}
+
+	if got := solveTestcase(testcase).AccessiblePoints; !samePoints(got, want) {
+		t.Errorf("visible = %v, want %v", got, want)
+	}
+}
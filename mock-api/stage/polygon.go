@@ -0,0 +1,163 @@
+package stage
+
+import (
+	"math"
+)
+
+// StagePolygon asks which targets are visible from the origin when a simple
+// polygon blocks the view.
+type StagePolygon struct {
+	edgeCases[PolygonTestCase]
+}
+
+type PolygonTestCase struct {
+	// Obstacle are the vertices of the polygon in counter-clockwise order.
+	Obstacle []Point `json:"obstacle"`
+	Targets  []Point `json:"targets"`
+}
+
+type PolygonSolution struct {
+	VisiblePoints []Point `json:"visiblePoints"`
+}
+
+func NewStagePolygon(opts Options) (StagePolygon, error) {
+	edgeCases, err := loadEdgeCases[PolygonTestCase]("polygon", opts, nil)
+	return StagePolygon{edgeCases: edgeCases}, err
+}
+
+func (s StagePolygon) Describe() Description {
+	example := PolygonTestCase{
+		Obstacle: []Point{{X: 2, Y: 1}, {X: 4, Y: 1}, {X: 4, Y: 3}, {X: 2, Y: 3}},
+		Targets:  []Point{{X: 6, Y: 3}, {X: 6, Y: 6}, {X: 3, Y: 2}, {X: 1, Y: 1}, {X: -5, Y: 2}},
+	}
+
+	return Description{
+		Title:     "Polygon",
+		Statement: string(doc("polygon.md")),
+		Schemas: Schemas{
+			Testcase: doc("polygon.testcase.schema.json"),
+			Solution: doc("polygon.solution.schema.json"),
+		},
+		Example: Example{
+			Testcase: example,
+			Solution: solvePolygonTestcase(example),
+		},
+	}
+}
+
+func (p Point) sub(q Point) Point {
+	return Point{X: p.X - q.X, Y: p.Y - q.Y}
+}
+
+// orientation is positive if r lies left of the directed line from p to q,
+// negative if right of it and zero if the three points are collinear.
+func orientation(p, q, r Point) float64 {
+	return q.sub(p).cross(r.sub(p))
+}
+
+// onSegment reports whether p, known to be collinear with a and b, lies on
+// the segment between them.
+func onSegment(p, a, b Point) bool {
+	return math.Min(a.X, b.X) <= p.X && p.X <= math.Max(a.X, b.X) &&
+		math.Min(a.Y, b.Y) <= p.Y && p.Y <= math.Max(a.Y, b.Y)
+}
+
+// segmentsIntersect reports whether the closed segments p1p2 and q1q2 have a
+// point in common.
+func segmentsIntersect(p1, p2, q1, q2 Point) bool {
+	d1 := orientation(q1, q2, p1)
+	d2 := orientation(q1, q2, p2)
+	d3 := orientation(p1, p2, q1)
+	d4 := orientation(p1, p2, q2)
+
+	if ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) && ((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0)) {
+		return true
+	}
+	return (d1 == 0 && onSegment(p1, q1, q2)) ||
+		(d2 == 0 && onSegment(p2, q1, q2)) ||
+		(d3 == 0 && onSegment(q1, p1, p2)) ||
+		(d4 == 0 && onSegment(q2, p1, p2))
+}
+
+// solvePolygonTestcase returns the targets whose line of sight from the
+// origin does not touch the polygon. The origin always lies outside of it,
+// so targets inside or on the polygon are hidden as well.
+func solvePolygonTestcase(testCase PolygonTestCase) PolygonSolution {
+	var origin Point
+	obstacle := testCase.Obstacle
+
+	visiblePoints := make([]Point, 0)
+	for _, target := range testCase.Targets {
+		visible := true
+		for i := range obstacle {
+			a, b := obstacle[i], obstacle[(i+1)%len(obstacle)]
+			if segmentsIntersect(origin, target, a, b) {
+				visible = false
+				break
+			}
+		}
+		if visible {
+			visiblePoints = append(visiblePoints, target)
+		}
+	}
+
+	return PolygonSolution{
+		VisiblePoints: visiblePoints,
+	}
+}
+
+// CreateTestcase generates a polygon that is star-shaped around a center
+// away from the origin, which makes it simple. Its vertices are spread over
+// sectors of the full circle around that center.
+func (s StagePolygon) CreateTestcase(token string, nr int) PolygonTestCase {
+	testcase, nr, curated := s.pick(token, nr)
+	if curated {
+		return testcase
+	}
+
+	randGen := RandFromTokenAndTestcase(token, nr)
+
+	const minRadius, maxRadius = 5.0, 20.0
+	vertices := 3 + nr
+	centerAngle := randGen.Float64() * 2 * math.Pi
+	centerDistance := maxRadius + 5 + randGen.Float64()*75
+	center := Point{
+		X: centerDistance * math.Cos(centerAngle),
+		Y: centerDistance * math.Sin(centerAngle),
+	}
+
+	obstacle := make([]Point, vertices)
+	for i := range obstacle {
+		// Jittering within a sector keeps the angles in order and distinct.
+		angle := 2 * math.Pi * (float64(i) + randGen.Float64()*0.8) / float64(vertices)
+		radius := minRadius + randGen.Float64()*(maxRadius-minRadius)
+		obstacle[i] = Point{
+			X: center.X + radius*math.Cos(angle),
+			Y: center.Y + radius*math.Sin(angle),
+		}
+	}
+
+	targets := make([]Point, 10*nr*nr)
+	for i := range targets {
+		targets[i] = Point{
+			X: randGen.Float64()*240 - 120,
+			Y: randGen.Float64()*240 - 120,
+		}
+	}
+
+	return PolygonTestCase{
+		Obstacle: obstacle,
+		Targets:  targets,
+	}
+}
+
+func (s StagePolygon) GetSolution(token string, nr int) PolygonSolution {
+	testcase := s.CreateTestcase(token, nr)
+	return solvePolygonTestcase(testcase)
+}
+
+func (s StagePolygon) ValidateSolution(token string, nr int, solution PolygonSolution) bool {
+	validSolution := s.GetSolution(token, nr)
+
+	return samePoints(solution.VisiblePoints, validSolution.VisiblePoints)
+}
@@ -0,0 +1,138 @@
+package stage
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// insidePolygon reports whether p lies strictly inside the polygon, by
+// counting the edges a ray from p to the right crosses.
+func insidePolygon(p Point, polygon []Point) bool {
+	inside := false
+	for i := range polygon {
+		a, b := polygon[i], polygon[(i+1)%len(polygon)]
+		if (a.Y > p.Y) != (b.Y > p.Y) && p.X < a.X+(p.Y-a.Y)*(b.X-a.X)/(b.Y-a.Y) {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// polygonOracle solves a testcase exactly: a target is hidden if it lies
+// inside the polygon or the segment from the origin to it shares a point
+// with an edge.
+func polygonOracle(testcase PolygonTestCase) []Point {
+	origin := exact(Point{})
+	obstacle := testcase.Obstacle
+
+	visible := []Point{}
+	for _, target := range testcase.Targets {
+		hidden := insidePolygon(target, obstacle)
+		for i := range obstacle {
+			a, b := obstacle[i], obstacle[(i+1)%len(obstacle)]
+			if segmentsIntersectExact(origin, exact(target), exact(a), exact(b)) {
+				hidden = true
+			}
+		}
+		if !hidden {
+			visible = append(visible, target)
+		}
+	}
+	return visible
+}
+
+func checkPolygonAgainstOracle(t *testing.T, name string, testcase PolygonTestCase) {
+	t.Helper()
+
+	got := solvePolygonTestcase(testcase).VisiblePoints
+	want := polygonOracle(testcase)
+	if !samePoints(got, want) {
+		t.Errorf("%s: obstacle %v\n got %v\nwant %v", name, testcase.Obstacle, got, want)
+	}
+}
+
+// randomPolygon returns a polygon star-shaped around a center away from the
+// origin, like the generated ones, with integer vertices.
+func randomPolygon(r *rand.Rand) []Point {
+	// The polygon reaches 15 from its center, which keeps it off the origin.
+	centerAngle := r.Float64() * 2 * math.Pi
+	centerDistance := 20 + r.Float64()*25
+	center := Point{X: centerDistance * math.Cos(centerAngle), Y: centerDistance * math.Sin(centerAngle)}
+	vertices := 3 + r.Intn(8)
+	polygon := make([]Point, vertices)
+	for i := range polygon {
+		angle := 2 * math.Pi * (float64(i) + r.Float64()*0.8) / float64(vertices)
+		radius := 5 + r.Float64()*10
+		polygon[i] = Point{
+			X: math.Round(center.X + radius*math.Cos(angle)),
+			Y: math.Round(center.Y + radius*math.Sin(angle)),
+		}
+	}
+	return polygon
+}
+
+// polygonTargets places targets anywhere, on and inside the polygon and
+// behind its vertices, all at integer coordinates.
+func polygonTargets(r *rand.Rand, polygon []Point) []Point {
+	var targets []Point
+	for range 40 {
+		targets = append(targets, Point{X: randInt(r, -70, 70), Y: randInt(r, -70, 70)})
+	}
+	var center Point
+	for _, v := range polygon {
+		center.X += v.X
+		center.Y += v.Y
+		targets = append(targets, v, Point{X: 2 * v.X, Y: 2 * v.Y})
+	}
+	targets = append(targets, Point{
+		X: math.Round(center.X / float64(len(polygon))),
+		Y: math.Round(center.Y / float64(len(polygon))),
+	})
+	return targets
+}
+
+func TestSolvePolygonTestcaseMatchesOracle(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	for i := range 300 {
+		polygon := randomPolygon(r)
+		checkPolygonAgainstOracle(t, fmt.Sprintf("random %d", i), PolygonTestCase{
+			Obstacle: polygon,
+			Targets:  polygonTargets(r, polygon),
+		})
+	}
+}
+
+func TestStagePolygonTestcasesMatchOracle(t *testing.T) {
+	s, err := NewStagePolygon(Options{EdgeCaseInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, token := range []string{"a", "b", "c"} {
+		for nr := 1; nr <= 6; nr++ {
+			checkPolygonAgainstOracle(t, fmt.Sprintf("token %s testcase %d", token, nr), s.CreateTestcase(token, nr))
+		}
+	}
+}
+
+func TestPolygonHidesTargetsInsideAndOnIt(t *testing.T) {
+	square := []Point{{X: 2, Y: 1}, {X: 4, Y: 1}, {X: 4, Y: 3}, {X: 2, Y: 3}}
+	testcase := PolygonTestCase{
+		Obstacle: square,
+		Targets: []Point{
+			// Inside, on a vertex, on an edge and behind the square.
+			{X: 3, Y: 2}, {X: 2, Y: 1}, {X: 4, Y: 3}, {X: 3, Y: 1}, {X: 8, Y: 4},
+			// The line of sight only grazes the vertex at (2, 3).
+			{X: 4, Y: 6},
+			// In front of, beside and opposite the square.
+			{X: 1, Y: 0.5}, {X: 5, Y: 0}, {X: 1, Y: 4}, {X: -3, Y: -2},
+		},
+	}
+	want := []Point{{X: 1, Y: 0.5}, {X: 5, Y: 0}, {X: 1, Y: 4}, {X: -3, Y: -2}}
+
+	if got := solvePolygonTestcase(testcase).VisiblePoints; !samePoints(got, want) {
+		t.Errorf("visible points = %v, want %v", got, want)
+	}
+}
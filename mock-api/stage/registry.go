@@ -0,0 +1,280 @@
+package stage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// ErrMalformedSolution is returned by Runner.Validate if the submitted
+// solution could not be decoded.
+var ErrMalformedSolution = errors.New("malformed solution")
+
+// Runner is the type erased form of a Stage the HTTP handlers work with.
+type Runner interface {
+	// Testcases returns how many testcases are served for the given number
+	// of generated ones, which is more if curated ones are inserted.
+	Testcases(generated int) int
+	// Testcase only fails for stages whose testcases can fail to be
+	// created, like file stages with a generator.
+	Testcase(token string, nr int) (any, error)
+	Validate(token string, nr int, body io.Reader) (bool, error)
+	// Describe returns an empty Description if the stage does not
+	// implement Describer.
+	Describe() Description
+}
+
+type runner[T any, S any] struct {
+	stage Stage[T, S]
+	// schema validates solutions before they are decoded, if the stage
+	// declares one.
+	schema        *jsonschema.Schema
+	appliedSchema json.RawMessage
+}
+
+// NewRunner wraps a Stage so it can be stored in a Registry. Solutions are
+// validated against the solution schema of stages implementing Describer.
+func NewRunner[T any, S any](s Stage[T, S], opts Options) (Runner, error) {
+	return newRunner(s, opts)
+}
+
+func newRunner[T any, S any](s Stage[T, S], opts Options) (runner[T, S], error) {
+	r := runner[T, S]{stage: s}
+	if d, ok := any(s).(Describer); ok {
+		if schema := d.Describe().Schemas.Solution; schema != nil {
+			var err error
+			r.schema, r.appliedSchema, err = compileSchema("solution.schema.json", schema, opts.Strict)
+			if err != nil {
+				return r, err
+			}
+		}
+	}
+	return r, nil
+}
+
+// testcaseCounter is implemented by stages with edge cases.
+type testcaseCounter interface {
+	testcases(generated int) int
+}
+
+func (r runner[T, S]) Testcases(generated int) int {
+	if c, ok := any(r.stage).(testcaseCounter); ok {
+		return c.testcases(generated)
+	}
+	return generated
+}
+
+// testcaseGenerator is implemented by stages whose testcases can fail to be
+// created, CreateTestcase returns the zero value then.
+type testcaseGenerator[T any] interface {
+	generateTestcase(token string, nr int) (T, error)
+}
+
+func (r runner[T, S]) Testcase(token string, nr int) (any, error) {
+	if g, ok := any(r.stage).(testcaseGenerator[T]); ok {
+		return g.generateTestcase(token, nr)
+	}
+	return r.stage.CreateTestcase(token, nr), nil
+}
+
+func (r runner[T, S]) Validate(token string, nr int, body io.Reader) (bool, error) {
+	encoded, err := io.ReadAll(body)
+	if err != nil {
+		return false, err
+	}
+
+	solution, err := decode[S](r.schema, encoded, ErrMalformedSolution)
+	if err != nil {
+		return false, err
+	}
+	return r.stage.ValidateSolution(token, nr, solution), nil
+}
+
+func (r runner[T, S]) Describe() Description {
+	d, ok := any(r.stage).(Describer)
+	if !ok {
+		return Description{}
+	}
+	description := d.Describe()
+	if r.appliedSchema != nil {
+		description.Schemas.Solution = r.appliedSchema
+	}
+	return description
+}
+
+var kinds = map[string]func(Options) (Runner, error){
+	"points-c":      runnerOf[TestCase, Solution](NewStagePointC),
+	"polygon":       runnerOf[PolygonTestCase, PolygonSolution](NewStagePolygon),
+	"shortest-path": runnerOf[GraphTestCase, GraphSolution](NewStageShortestPath),
+	"maze":          interactiveRunnerOf[MazeTestCase, MazeSolution, MazeMove, MazeView](NewStageMaze),
+	"text":          runnerOf[TextTestCase, TextSolution](NewStageText),
+}
+
+// runnerOf turns the constructor of a stage into one of its Runner. The
+// testcase and solution types can not be inferred and have to be given.
+func runnerOf[T any, S any, St Stage[T, S]](create func(Options) (St, error)) func(Options) (Runner, error) {
+	return func(opts Options) (Runner, error) {
+		s, err := create(opts)
+		if err != nil {
+			return nil, err
+		}
+		return NewRunner[T, S](s, opts)
+	}
+}
+
+// interactiveRunnerOf is runnerOf for interactive stages.
+func interactiveRunnerOf[T any, S any, M any, R any, St InteractiveStage[T, S, M, R]](create func(Options) (St, error)) func(Options) (Runner, error) {
+	return func(opts Options) (Runner, error) {
+		s, err := create(opts)
+		if err != nil {
+			return nil, err
+		}
+		return NewInteractiveRunner[T, S, M, R](s, opts)
+	}
+}
+
+// maxTestcases bounds the testcases of a stage kind, as testcases grow
+// with their number. Kinds not listed allow DefaultMaxTestcases.
+var maxTestcases = map[string]int{
+	// Testcase nr has 3^nr targets, 12 are about half a million.
+	"points-c": 12,
+	// Testcase nr has 10*nr^2 targets and 3+nr vertices, 30 are 9000
+	// targets.
+	"polygon": 30,
+	// Testcase nr has 10*nr^2 nodes and 40*nr^2 edges, 30 are 36000 edges.
+	"shortest-path": 30,
+}
+
+// DefaultMaxTestcases is the maximum number of testcases of a stage, unless
+// its kind allows less.
+const DefaultMaxTestcases = 100
+
+// MaxTestcases returns how many testcases a stage of kind may have.
+func MaxTestcases(kind string) int {
+	if limit, exists := maxTestcases[kind]; exists {
+		return limit
+	}
+	return DefaultMaxTestcases
+}
+
+// Kinds returns the names of all stage kinds that can be created with New.
+func Kinds() []string {
+	names := make([]string, 0, len(kinds))
+	for name := range kinds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New creates a Runner for the stage kind with the given name.
+func New(kind string, opts Options) (Runner, error) {
+	create, exists := kinds[kind]
+	if !exists {
+		return nil, fmt.Errorf("unknown stage kind %q", kind)
+	}
+	return create(opts)
+}
+
+// Entry is a stage as it is served under /assignment/{mnr}/stage/{ID}.
+type Entry struct {
+	ID        string
+	Kind      string
+	Testcases int
+	Runner    Runner
+}
+
+// Registry holds the stages served by the mock, keyed by their ID.
+type Registry struct {
+	mu      sync.RWMutex
+	entries map[string]Entry
+	order   []string
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		entries: map[string]Entry{},
+	}
+}
+
+func (r *Registry) Register(e Entry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.entries[e.ID]; exists {
+		return fmt.Errorf("stage %q is already registered", e.ID)
+	}
+	r.entries[e.ID] = e
+	r.order = append(r.order, e.ID)
+	return nil
+}
+
+func (r *Registry) Get(id string) (Entry, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	e, exists := r.entries[id]
+	return e, exists
+}
+
+// List returns all entries in registration order.
+func (r *Registry) List() []Entry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entries := make([]Entry, 0, len(r.order))
+	for _, id := range r.order {
+		entries = append(entries, r.entries[id])
+	}
+	return entries
+}
+
+// Ping reports an error if no stage is available to be served.
+func (r *Registry) Ping(ctx context.Context) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if len(r.entries) == 0 {
+		return errors.New("no stages registered")
+	}
+	return ctx.Err()
+}
+
+// ReplaceKind swaps all entries of a kind for the given ones at once, the
+// new entries are ordered after the remaining ones. Nothing is changed if
+// an ID of the new entries is taken by a stage of another kind, or if an
+// entry would get fewer testcases than it had, as students may have solved
+// the dropped ones already.
+func (r *Registry) ReplaceKind(kind string, replacements []Entry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entries := map[string]Entry{}
+	var order []string
+	for _, id := range r.order {
+		if e := r.entries[id]; e.Kind != kind {
+			entries[id] = e
+			order = append(order, id)
+		}
+	}
+	for _, e := range replacements {
+		if _, exists := entries[e.ID]; exists {
+			return fmt.Errorf("stage %q is already registered", e.ID)
+		}
+		if previous, exists := r.entries[e.ID]; exists && e.Testcases < previous.Testcases {
+			return fmt.Errorf("stage %q would shrink from %d to %d testcases, which takes a restart", e.ID, previous.Testcases, e.Testcases)
+		}
+		entries[e.ID] = e
+		order = append(order, e.ID)
+	}
+
+	r.entries = entries
+	r.order = order
+	return nil
+}
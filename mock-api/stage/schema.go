@@ -0,0 +1,160 @@
+package stage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+var printer = message.NewPrinter(language.English)
+
+// Options configure how a Runner serves testcases and validates solutions.
+type Options struct {
+	// Strict rejects properties a solution schema does not declare, as if
+	// every object in it had "additionalProperties": false.
+	Strict bool
+	// CasesDir holds <kind>.json files with curated testcases, which replace
+	// the embedded ones of their stage kind.
+	CasesDir string
+	// EdgeCaseInterval is how many generated testcases precede every
+	// curated one. Zero uses DefaultEdgeCaseInterval, a negative interval
+	// serves generated testcases only.
+	EdgeCaseInterval int
+	// StateTTL is how long interactive stages keep the state of a testcase
+	// after its last move, forever if it is zero.
+	StateTTL time.Duration
+}
+
+// Violation is a part of a solution that does not match the schema, Field
+// being a JSON pointer to it.
+type Violation struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// SchemaError is returned for solutions and moves that are valid JSON but
+// do not match their schema. It wraps ErrMalformedSolution respectively
+// ErrMalformedMove.
+type SchemaError struct {
+	Err        error
+	Violations []Violation
+}
+
+func (e *SchemaError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		messages[i] = v.Field + ": " + v.Message
+	}
+	return fmt.Sprintf("%v: does not match schema: %s", e.Err, strings.Join(messages, "; "))
+}
+
+func (e *SchemaError) Unwrap() error {
+	return e.Err
+}
+
+// compileSchema compiles the JSON Schema raw. It also returns the schema as
+// applied, which differs from raw in strict mode.
+func compileSchema(name string, raw json.RawMessage, strict bool) (*jsonschema.Schema, json.RawMessage, error) {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(raw))
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse schema %s: %w", name, err)
+	}
+
+	if strict {
+		forbidAdditionalProperties(doc)
+		if raw, err = json.MarshalIndent(doc, "", "  "); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource(name, doc); err != nil {
+		return nil, nil, err
+	}
+	schema, err := compiler.Compile(name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("compile schema %s: %w", name, err)
+	}
+	return schema, raw, nil
+}
+
+// forbidAdditionalProperties sets "additionalProperties" to false in every
+// schema declaring properties, unless it is set already.
+func forbidAdditionalProperties(v any) {
+	switch v := v.(type) {
+	case map[string]any:
+		if _, hasProperties := v["properties"]; hasProperties {
+			if _, set := v["additionalProperties"]; !set {
+				v["additionalProperties"] = false
+			}
+		}
+		for _, child := range v {
+			forbidAdditionalProperties(child)
+		}
+	case []any:
+		for _, child := range v {
+			forbidAdditionalProperties(child)
+		}
+	}
+}
+
+// decode validates body against schema, if there is one, and unmarshals
+// it. Errors wrap malformed.
+func decode[V any](schema *jsonschema.Schema, body []byte, malformed error) (V, error) {
+	var v V
+	if schema != nil {
+		instance, err := jsonschema.UnmarshalJSON(bytes.NewReader(body))
+		if err != nil {
+			return v, fmt.Errorf("%w: %w", malformed, err)
+		}
+
+		var validationErr *jsonschema.ValidationError
+		if err := schema.Validate(instance); errors.As(err, &validationErr) {
+			return v, &SchemaError{Err: malformed, Violations: violations(validationErr)}
+		} else if err != nil {
+			return v, err
+		}
+	}
+
+	if err := json.Unmarshal(body, &v); err != nil {
+		return v, fmt.Errorf("%w: %w", malformed, err)
+	}
+	return v, nil
+}
+
+// violations flattens the tree of validation errors into the failed leaves.
+func violations(err *jsonschema.ValidationError) []Violation {
+	if len(err.Causes) == 0 {
+		return []Violation{{
+			Field:   jsonPointer(err.InstanceLocation),
+			Message: err.ErrorKind.LocalizedString(printer),
+		}}
+	}
+
+	var result []Violation
+	for _, cause := range err.Causes {
+		result = append(result, violations(cause)...)
+	}
+	return result
+}
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+func jsonPointer(tokens []string) string {
+	if len(tokens) == 0 {
+		return "/"
+	}
+	var sb strings.Builder
+	for _, token := range tokens {
+		sb.WriteString("/")
+		sb.WriteString(pointerEscaper.Replace(token))
+	}
+	return sb.String()
+}
@@ -0,0 +1,148 @@
+package stage
+
+import (
+	"strings"
+	"unicode"
+)
+
+// StageText asks to decode a Caesar cipher and to list the words of the
+// decoded text. Both answers are compared with the normalization rules in
+// textNormalization.
+type StageText struct {
+	edgeCases[TextTestCase]
+}
+
+type TextTestCase struct {
+	// Shift is how far every letter was moved forward in the alphabet.
+	Shift      int    `json:"shift"`
+	Ciphertext string `json:"ciphertext"`
+}
+
+type TextSolution struct {
+	Plaintext string `json:"plaintext"`
+	// Words are the distinct words of the plaintext.
+	Words []string `json:"words"`
+}
+
+var textNormalization = struct {
+	Plaintext Normalization
+	Words     Normalization
+}{
+	Plaintext: Normalization{CollapseSpace: true, IgnoreCase: true},
+	Words:     Normalization{TrimSpace: true, IgnoreCase: true, IgnoreOrder: true},
+}
+
+var textWords = []string{
+	"algorithm", "binary", "cache", "compiler", "data", "debug", "exam",
+	"function", "graph", "heap", "index", "kernel", "lambda", "matrix",
+	"network", "object", "pointer", "query", "queue", "recursion", "server",
+	"socket", "stack", "student", "thread", "token", "tree", "vector",
+	"the", "a", "and", "of", "to", "is", "in", "with", "for", "every",
+}
+
+func NewStageText(opts Options) (StageText, error) {
+	edgeCases, err := loadEdgeCases[TextTestCase]("text", opts, nil)
+	return StageText{edgeCases: edgeCases}, err
+}
+
+func (s StageText) Describe() Description {
+	example := TextTestCase{Shift: 3, Ciphertext: "Wkh  vwdfn\nlv d vwdfn"}
+
+	return Description{
+		Title:     "Text",
+		Statement: string(doc("text.md")),
+		Schemas: Schemas{
+			Testcase: doc("text.testcase.schema.json"),
+			Solution: doc("text.solution.schema.json"),
+		},
+		Example: Example{
+			Testcase: example,
+			Solution: solveTextTestcase(example),
+		},
+		Normalization: map[string]Normalization{
+			"/plaintext": textNormalization.Plaintext,
+			"/words":     textNormalization.Words,
+		},
+	}
+}
+
+// shiftLetter moves ASCII letters by shift places, wrapping around the
+// alphabet and keeping their case. Other characters are kept as they are.
+func shiftLetter(r rune, shift int) rune {
+	shift = (shift%26 + 26) % 26
+	switch {
+	case r >= 'a' && r <= 'z':
+		return 'a' + (r-'a'+rune(shift))%26
+	case r >= 'A' && r <= 'Z':
+		return 'A' + (r-'A'+rune(shift))%26
+	}
+	return r
+}
+
+func solveTextTestcase(testCase TextTestCase) TextSolution {
+	plaintext := strings.Map(func(r rune) rune {
+		return shiftLetter(r, -testCase.Shift)
+	}, testCase.Ciphertext)
+
+	words := make([]string, 0)
+	seen := map[string]bool{}
+	for _, word := range strings.FieldsFunc(plaintext, func(r rune) bool { return !unicode.IsLetter(r) }) {
+		word = strings.ToLower(word)
+		if !seen[word] {
+			seen[word] = true
+			words = append(words, word)
+		}
+	}
+
+	return TextSolution{
+		Plaintext: plaintext,
+		Words:     words,
+	}
+}
+
+// CreateTestcase builds a text from random words in random case, separated
+// by irregular white space and punctuation, and encrypts it.
+func (s StageText) CreateTestcase(token string, nr int) TextTestCase {
+	testcase, nr, curated := s.pick(token, nr)
+	if curated {
+		return testcase
+	}
+
+	randGen := RandFromTokenAndTestcase(token, nr)
+
+	separators := []string{" ", " ", " ", "  ", ", ", ". ", "\n", "\t"}
+	var plaintext strings.Builder
+	for i := range 5 * nr * nr {
+		if i > 0 {
+			plaintext.WriteString(separators[randGen.Intn(len(separators))])
+		}
+		word := textWords[randGen.Intn(len(textWords))]
+		switch randGen.Intn(4) {
+		case 0:
+			word = strings.ToUpper(word[:1]) + word[1:]
+		case 1:
+			word = strings.ToUpper(word)
+		}
+		plaintext.WriteString(word)
+	}
+
+	shift := 1 + randGen.Intn(25)
+	return TextTestCase{
+		Shift: shift,
+		Ciphertext: strings.Map(func(r rune) rune {
+			return shiftLetter(r, shift)
+		}, plaintext.String()),
+	}
+}
+
+func (s StageText) GetSolution(token string, nr int) TextSolution {
+	testcase := s.CreateTestcase(token, nr)
+	return solveTextTestcase(testcase)
+}
+
+func (s StageText) ValidateSolution(token string, nr int, solution TextSolution) bool {
+	validSolution := s.GetSolution(token, nr)
+
+	return textNormalization.Plaintext.Equal(solution.Plaintext, validSolution.Plaintext) &&
+		textNormalization.Words.EqualLists(solution.Words, validSolution.Words)
+}
@@ -0,0 +1,87 @@
+package stage
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog/log"
+)
+
+// reloadDelay collects the events of an editor saving several files into a
+// single reload.
+const reloadDelay = 250 * time.Millisecond
+
+// WatchFileStages reloads the file stages of root into registry whenever a
+// file below root changes, until ctx is done. The stages are swapped all at
+// once; if any of them fails to load or would lose testcases, see
+// Registry.ReplaceKind, the error is logged and the previous stages are kept.
+func WatchFileStages(ctx context.Context, root string, opts Options, registry *Registry) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err := watchDirs(watcher, root); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	go func() {
+		defer watcher.Close()
+
+		// Stopped until the first event arrives.
+		reload := time.NewTimer(reloadDelay)
+		reload.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event := <-watcher.Events:
+				if event.Op == fsnotify.Chmod {
+					continue
+				}
+				reload.Reset(reloadDelay)
+			case err := <-watcher.Errors:
+				log.Err(err).Str("stageDir", root).Msg("Error watching file stages")
+			case <-reload.C:
+				// New directories have to be watched as well.
+				if err := watchDirs(watcher, root); err != nil {
+					log.Err(err).Str("stageDir", root).Msg("Could not watch file stages")
+				}
+				reloadFileStages(root, opts, registry)
+			}
+		}
+	}()
+	return nil
+}
+
+func reloadFileStages(root string, opts Options, registry *Registry) {
+	entries, err := LoadFileStages(root, opts)
+	if err != nil {
+		log.Err(err).Str("stageDir", root).Msg("Could not reload file stages, keeping the previous ones")
+		return
+	}
+	if err := registry.ReplaceKind(FileKind, entries); err != nil {
+		log.Err(err).Str("stageDir", root).Msg("Could not reload file stages, keeping the previous ones")
+		return
+	}
+
+	ids := make([]string, len(entries))
+	for i, e := range entries {
+		ids[i] = e.ID
+	}
+	log.Info().Str("stageDir", root).Strs("stages", ids).Msg("Reloaded file stages")
+}
+
+// watchDirs adds root and all directories below it to watcher, as fsnotify
+// does not watch recursively.
+func watchDirs(watcher *fsnotify.Watcher, root string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+		return watcher.Add(path)
+	})
+}
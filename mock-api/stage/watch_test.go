@@ -0,0 +1,144 @@
+package stage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func ids(r *Registry) []string {
+	var ids []string
+	for _, e := range r.List() {
+		ids = append(ids, fmt.Sprintf("%s:%d", e.ID, e.Testcases))
+	}
+	return ids
+}
+
+func TestReplaceKind(t *testing.T) {
+	r := NewRegistry()
+	for _, e := range []Entry{
+		{ID: "1", Kind: "points-c", Testcases: 10},
+		{ID: "a", Kind: FileKind, Testcases: 3},
+		{ID: "b", Kind: FileKind, Testcases: 1},
+	} {
+		if err := r.Register(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name         string
+		replacements []Entry
+		wantErr      bool
+		want         []string
+	}{
+		{"duplicate id", []Entry{{ID: "1", Kind: FileKind, Testcases: 3}}, true, []string{"1:10", "a:3", "b:1"}},
+		{"fewer testcases", []Entry{{ID: "a", Kind: FileKind, Testcases: 2}}, true, []string{"1:10", "a:3", "b:1"}},
+		{"more testcases and a new stage", []Entry{{ID: "c", Kind: FileKind, Testcases: 1}, {ID: "a", Kind: FileKind, Testcases: 4}}, false, []string{"1:10", "c:1", "a:4"}},
+	}
+	for _, tt := range tests {
+		if err := r.ReplaceKind(FileKind, tt.replacements); (err != nil) != tt.wantErr {
+			t.Errorf("%s: ReplaceKind() = %v, want an error: %v", tt.name, err, tt.wantErr)
+		}
+		if got := ids(r); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: stages %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// writeStage writes a stage with fixed testcases to root/name.
+func writeStage(t *testing.T, root, name, manifest string, testcases int) {
+	t.Helper()
+
+	dir := filepath.Join(root, name)
+	if err := os.MkdirAll(filepath.Join(dir, "testcases"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{manifestName: manifest}
+	for nr := 1; nr <= testcases; nr++ {
+		files[fmt.Sprintf("testcases/%d.input.json", nr)] = fmt.Sprintf(`{"nr": %d}`, nr)
+		files[fmt.Sprintf("testcases/%d.output.json", nr)] = fmt.Sprintf(`{"nr": %d}`, nr)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// waitForStages waits until the registry holds want, or fails after a
+// deadline.
+func waitForStages(t *testing.T, r *Registry, want []string) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !slices.Equal(ids(r), want) {
+		if time.Now().After(deadline) {
+			t.Fatalf("stages %v, want %v", ids(r), want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// keepsStages checks that the registry still holds want once a reload
+// triggered by the last change had the chance to happen.
+func keepsStages(t *testing.T, r *Registry, want []string) {
+	t.Helper()
+
+	time.Sleep(reloadDelay + 250*time.Millisecond)
+	if got := ids(r); !slices.Equal(got, want) {
+		t.Errorf("stages %v, want the previous %v", got, want)
+	}
+}
+
+func TestWatchFileStages(t *testing.T) {
+	root := t.TempDir()
+	writeStage(t, root, "a", "title: A\n", 2)
+	entries, err := LoadFileStages(root, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewRegistry()
+	for _, e := range entries {
+		r.Register(e)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := WatchFileStages(ctx, root, Options{}, r); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new directory is picked up, including the files written into it
+	// after it was created.
+	writeStage(t, root, "b", "title: B\n", 1)
+	waitForStages(t, r, []string{"a:2", "b:1"})
+
+	// A stage failing to load keeps all previous ones.
+	writeStage(t, root, "c", "title: [\n", 1)
+	keepsStages(t, r, []string{"a:2", "b:1"})
+	if err := os.RemoveAll(filepath.Join(root, "c")); err != nil {
+		t.Fatal(err)
+	}
+
+	// A duplicate ID keeps the previous stages as well.
+	writeStage(t, root, "d", "id: a\n", 1)
+	keepsStages(t, r, []string{"a:2", "b:1"})
+	if err := os.RemoveAll(filepath.Join(root, "d")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Dropping a testcase would lose the progress on it.
+	if err := os.Remove(filepath.Join(root, "a", "testcases", "2.input.json")); err != nil {
+		t.Fatal(err)
+	}
+	keepsStages(t, r, []string{"a:2", "b:1"})
+
+	// Adding testcases is fine.
+	writeStage(t, root, "a", "title: A\n", 3)
+	waitForStages(t, r, []string{"a:3", "b:1"})
+}
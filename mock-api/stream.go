@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Fancy11111/ase-prep/mock-api/events"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	eventBuffer    = 256
+	eventKeepAlive = 15 * time.Second
+)
+
+// streamEvents sends the events published on the bus as server-sent events,
+// optionally filtered by the mnr, stage and type query parameters. type
+// takes a comma separated list.
+func (h Handler) streamEvents(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := events.Filter{
+		Mnr:   query.Get("mnr"),
+		Stage: query.Get("stage"),
+	}
+	if v := query.Get("type"); v != "" {
+		for _, name := range strings.Split(v, ",") {
+			t, err := events.ParseType(strings.TrimSpace(name))
+			if err != nil {
+				writeProblem(w, r, http.StatusBadRequest, codeInvalidFilter, err.Error())
+				return
+			}
+			filter.Types = append(filter.Types, t)
+		}
+	}
+
+	subscription := h.events.Subscribe(filter, eventBuffer)
+	defer subscription.Close()
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+
+	startEventStream(w)
+	var dropped int64
+	for {
+		var err error
+		select {
+		case e := <-subscription.Events():
+			err = writeEvent(w, strconv.FormatInt(e.ID, 10), string(e.Type), e)
+			if n := subscription.Dropped(); err == nil && n > dropped {
+				// Tells the client its view is incomplete.
+				err = writeEvent(w, "", "dropped", map[string]int64{"count": n - dropped})
+				dropped = n
+			}
+		case <-keepAlive.C:
+			err = writeKeepAlive(w)
+		case <-r.Context().Done():
+			return
+		case <-h.state.done():
+			return
+		}
+
+		if err != nil {
+			log.Ctx(r.Context()).Debug().Err(err).Msg("Event stream closed")
+			return
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package submission
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	// Pure Go driver, so the mock can still be built with CGO_ENABLED=0.
+	_ "modernc.org/sqlite"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS submissions (
+	id                INTEGER PRIMARY KEY AUTOINCREMENT,
+	mnr               TEXT    NOT NULL,
+	token_fingerprint TEXT    NOT NULL,
+	stage             TEXT    NOT NULL,
+	testcase          INTEGER NOT NULL,
+	payload           TEXT    NOT NULL,
+	verdict           TEXT    NOT NULL,
+	latency_ns        INTEGER NOT NULL,
+	submitted_at      INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS submissions_mnr ON submissions (mnr, submitted_at);
+CREATE INDEX IF NOT EXISTS submissions_stage ON submissions (stage, testcase);
+`
+
+// SQLiteStore persists submissions in a SQLite database file.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// OpenSQLite opens the database at path, creating it and its schema if needed.
+func OpenSQLite(path string) (*SQLiteStore, error) {
+	dsn := url.URL{
+		Scheme: "file",
+		// SQLite decodes %XX in file URIs, so paths may contain ? and #.
+		Opaque: strings.NewReplacer("%", "%25", "?", "%3F", "#", "%23").Replace(path),
+		RawQuery: url.Values{
+			"_pragma": {"busy_timeout(5000)", "journal_mode(WAL)"},
+		}.Encode(),
+	}
+	db, err := sql.Open("sqlite", dsn.String())
+	if err != nil {
+		return nil, fmt.Errorf("open submission database: %w", err)
+	}
+	// SQLite only supports a single writer, serialize access in the pool.
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create submission schema: %w", err)
+	}
+	return &SQLiteStore{db: db}, nil
+}
+
+func (s *SQLiteStore) Add(ctx context.Context, sub *Submission) error {
+	result, err := s.db.ExecContext(ctx,
+		`INSERT INTO submissions (mnr, token_fingerprint, stage, testcase, payload, verdict, latency_ns, submitted_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		sub.Mnr, sub.TokenFingerprint, sub.Stage, sub.Testcase, string(sub.Payload), string(sub.Verdict),
+		int64(sub.Latency), sub.SubmittedAt.UnixNano())
+	if err != nil {
+		return fmt.Errorf("insert submission: %w", err)
+	}
+
+	sub.ID, err = result.LastInsertId()
+	return err
+}
+
+const selectColumns = `SELECT id, mnr, token_fingerprint, stage, testcase, payload, verdict, latency_ns, submitted_at FROM submissions`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSubmission(row scanner) (Submission, error) {
+	var (
+		sub         Submission
+		payload     string
+		verdict     string
+		latency     int64
+		submittedAt int64
+	)
+	err := row.Scan(&sub.ID, &sub.Mnr, &sub.TokenFingerprint, &sub.Stage, &sub.Testcase, &payload, &verdict, &latency, &submittedAt)
+	if err != nil {
+		return sub, err
+	}
+	sub.Payload = []byte(payload)
+	sub.Verdict = Verdict(verdict)
+	sub.Latency = time.Duration(latency)
+	sub.SubmittedAt = time.Unix(0, submittedAt)
+	return sub, nil
+}
+
+func (s *SQLiteStore) Get(ctx context.Context, id int64) (Submission, error) {
+	sub, err := scanSubmission(s.db.QueryRowContext(ctx, selectColumns+` WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return sub, ErrNotFound
+	}
+	return sub, err
+}
+
+func (s *SQLiteStore) List(ctx context.Context, filter Filter) ([]Submission, error) {
+	var (
+		conditions []string
+		args       []any
+	)
+	where := func(condition string, arg any) {
+		conditions = append(conditions, condition)
+		args = append(args, arg)
+	}
+	if filter.Mnr != "" {
+		where("mnr = ?", filter.Mnr)
+	}
+	if filter.Stage != "" {
+		where("stage = ?", filter.Stage)
+	}
+	if filter.Testcase != 0 {
+		where("testcase = ?", filter.Testcase)
+	}
+	if filter.Verdict != "" {
+		where("verdict = ?", string(filter.Verdict))
+	}
+	if !filter.Since.IsZero() {
+		where("submitted_at >= ?", filter.Since.UnixNano())
+	}
+
+	query := selectColumns
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY submitted_at DESC, id DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query submissions: %w", err)
+	}
+	defer rows.Close()
+
+	result := []Submission{}
+	for rows.Next() {
+		sub, err := scanSubmission(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan submission: %w", err)
+		}
+		result = append(result, sub)
+	}
+	return result, rows.Err()
+}
+
+func (s *SQLiteStore) CountVerdicts(ctx context.Context) (map[string]VerdictCounts, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT stage, verdict, COUNT(*) FROM submissions GROUP BY stage, verdict`)
+	if err != nil {
+		return nil, fmt.Errorf("count submissions: %w", err)
+	}
+	defer rows.Close()
+
+	counts := map[string]VerdictCounts{}
+	for rows.Next() {
+		var (
+			stage   string
+			verdict string
+			count   int
+		)
+		if err := rows.Scan(&stage, &verdict, &count); err != nil {
+			return nil, fmt.Errorf("scan submission count: %w", err)
+		}
+		if counts[stage] == nil {
+			counts[stage] = VerdictCounts{}
+		}
+		counts[stage][Verdict(verdict)] = count
+	}
+	return counts, rows.Err()
+}
+
+func (s *SQLiteStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,164 @@
+package submission
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned by Store.Get for unknown IDs.
+var ErrNotFound = errors.New("submission not found")
+
+// Verdict is the outcome of a submission.
+type Verdict string
+
+const (
+	Accepted  Verdict = "accepted"
+	Rejected  Verdict = "rejected"
+	Malformed Verdict = "malformed"
+)
+
+// Submission is a solution posted by a student.
+type Submission struct {
+	ID               int64           `json:"id"`
+	Mnr              string          `json:"mnr"`
+	TokenFingerprint string          `json:"tokenFingerprint"`
+	Stage            string          `json:"stage"`
+	Testcase         int             `json:"testcase"`
+	Payload          json.RawMessage `json:"payload"`
+	Verdict          Verdict         `json:"verdict"`
+	// Latency is the time since the testcase was fetched, zero if unknown.
+	Latency     time.Duration `json:"latency"`
+	SubmittedAt time.Time     `json:"submittedAt"`
+}
+
+// PayloadFromBody converts a request body into a payload that can be stored,
+// bodies which are no valid JSON are kept as JSON string.
+func PayloadFromBody(body []byte) json.RawMessage {
+	if json.Valid(body) {
+		return json.RawMessage(body)
+	}
+	encoded, _ := json.Marshal(string(body))
+	return encoded
+}
+
+// VerdictCounts is the number of submissions per verdict.
+type VerdictCounts map[Verdict]int
+
+// Filter restricts the submissions returned by Store.List, zero values
+// match everything.
+type Filter struct {
+	Mnr      string
+	Stage    string
+	Testcase int
+	Verdict  Verdict
+	Since    time.Time
+	// Limit caps the number of results, the newest submissions are returned.
+	Limit int
+}
+
+func (f Filter) matches(s Submission) bool {
+	return (f.Mnr == "" || s.Mnr == f.Mnr) &&
+		(f.Stage == "" || s.Stage == f.Stage) &&
+		(f.Testcase == 0 || s.Testcase == f.Testcase) &&
+		(f.Verdict == "" || s.Verdict == f.Verdict) &&
+		(f.Since.IsZero() || !s.SubmittedAt.Before(f.Since))
+}
+
+// Store persists submissions.
+type Store interface {
+	// Add stores s and assigns its ID.
+	Add(ctx context.Context, s *Submission) error
+	Get(ctx context.Context, id int64) (Submission, error)
+	// List returns the matching submissions, newest first.
+	List(ctx context.Context, filter Filter) ([]Submission, error)
+	// CountVerdicts counts the submissions per stage and verdict.
+	CountVerdicts(ctx context.Context) (map[string]VerdictCounts, error)
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+// MemoryStore keeps submissions in memory, they are lost on restart.
+type MemoryStore struct {
+	mu          sync.RWMutex
+	submissions []Submission
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+func (m *MemoryStore) Add(ctx context.Context, s *Submission) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s.ID = int64(len(m.submissions) + 1)
+	m.submissions = append(m.submissions, *s)
+	return nil
+}
+
+func (m *MemoryStore) Get(ctx context.Context, id int64) (Submission, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if id < 1 || id > int64(len(m.submissions)) {
+		return Submission{}, ErrNotFound
+	}
+	return m.submissions[id-1], nil
+}
+
+func (m *MemoryStore) List(ctx context.Context, filter Filter) ([]Submission, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := []Submission{}
+	for i := len(m.submissions) - 1; i >= 0; i-- {
+		if filter.Limit > 0 && len(result) >= filter.Limit {
+			break
+		}
+		if filter.matches(m.submissions[i]) {
+			result = append(result, m.submissions[i])
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].SubmittedAt.After(result[j].SubmittedAt)
+	})
+	return result, nil
+}
+
+func (m *MemoryStore) CountVerdicts(ctx context.Context) (map[string]VerdictCounts, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	counts := map[string]VerdictCounts{}
+	for _, s := range m.submissions {
+		if counts[s.Stage] == nil {
+			counts[s.Stage] = VerdictCounts{}
+		}
+		counts[s.Stage][s.Verdict]++
+	}
+	return counts, nil
+}
+
+func (m *MemoryStore) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+func (m *MemoryStore) Close() error {
+	return nil
+}
+
+// Open creates the store of the given kind, path is only used by "sqlite".
+func Open(kind string, path string) (Store, error) {
+	switch kind {
+	case "memory":
+		return NewMemoryStore(), nil
+	case "sqlite":
+		return OpenSQLite(path)
+	}
+	return nil, fmt.Errorf("unknown submission store %q", kind)
+}
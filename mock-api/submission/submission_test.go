@@ -0,0 +1,159 @@
+package submission
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// stores returns an empty store of every kind.
+func stores(t *testing.T) map[string]Store {
+	t.Helper()
+
+	sqlite, err := OpenSQLite(filepath.Join(t.TempDir(), "submissions.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlite.Close() })
+	return map[string]Store{"memory": NewMemoryStore(), "sqlite": sqlite}
+}
+
+func TestStore(t *testing.T) {
+	start := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	submissions := []Submission{
+		{Mnr: "1", Stage: "a", Testcase: 1, Verdict: Rejected},
+		{Mnr: "1", Stage: "a", Testcase: 1, Verdict: Accepted},
+		{Mnr: "2", Stage: "a", Testcase: 2, Verdict: Malformed},
+		{Mnr: "2", Stage: "b", Testcase: 1, Verdict: Accepted},
+	}
+
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			for i, s := range submissions {
+				s.TokenFingerprint = "sha256:abc"
+				s.Payload = []byte(`{"answer":42}`)
+				s.Latency = time.Duration(i) * time.Second
+				s.SubmittedAt = start.Add(time.Duration(i) * time.Minute)
+				if err := store.Add(ctx, &s); err != nil {
+					t.Fatal(err)
+				}
+				if s.ID == 0 {
+					t.Fatal("Add did not assign an ID")
+				}
+				submissions[i].ID = s.ID
+			}
+
+			got, err := store.Get(ctx, submissions[2].ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Mnr != "2" || got.Verdict != Malformed || string(got.Payload) != `{"answer":42}` ||
+				got.Latency != 2*time.Second || !got.SubmittedAt.Equal(start.Add(2*time.Minute)) {
+				t.Errorf("Get() = %+v", got)
+			}
+			if _, err := store.Get(ctx, 999); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Get() of an unknown ID = %v, want ErrNotFound", err)
+			}
+
+			tests := []struct {
+				name   string
+				filter Filter
+				// want are indexes into submissions, newest first.
+				want []int
+			}{
+				{"all", Filter{}, []int{3, 2, 1, 0}},
+				{"mnr", Filter{Mnr: "1"}, []int{1, 0}},
+				{"stage and testcase", Filter{Stage: "a", Testcase: 1}, []int{1, 0}},
+				{"verdict", Filter{Verdict: Accepted}, []int{3, 1}},
+				{"since", Filter{Since: start.Add(2 * time.Minute)}, []int{3, 2}},
+				{"limit", Filter{Limit: 3}, []int{3, 2, 1}},
+				{"nothing", Filter{Mnr: "3"}, []int{}},
+			}
+			for _, tt := range tests {
+				list, err := store.List(ctx, tt.filter)
+				if err != nil {
+					t.Fatal(err)
+				}
+				ids := make([]int64, len(list))
+				for i, s := range list {
+					ids[i] = s.ID
+				}
+				want := make([]int64, len(tt.want))
+				for i, w := range tt.want {
+					want[i] = submissions[w].ID
+				}
+				if len(ids) != len(want) {
+					t.Errorf("%s: List() = %v, want %v", tt.name, ids, want)
+					continue
+				}
+				for i := range ids {
+					if ids[i] != want[i] {
+						t.Errorf("%s: List() = %v, want %v", tt.name, ids, want)
+						break
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSQLiteKeepsSubmissions(t *testing.T) {
+	// Characters with a meaning in URIs must not end the file name.
+	path := filepath.Join(t.TempDir(), "sub?missions#100%.db")
+	ctx := context.Background()
+
+	store, err := OpenSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := Submission{Mnr: "1", Stage: "a", Testcase: 1, Payload: []byte("{}"), Verdict: Accepted, SubmittedAt: time.Now()}
+	if err := store.Add(ctx, &s); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	store, err = OpenSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if got, err := store.Get(ctx, s.ID); err != nil || got.Mnr != "1" {
+		t.Errorf("Get() after reopening = %+v, %v", got, err)
+	}
+}
+
+func TestCountVerdicts(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			for _, s := range []Submission{
+				{Mnr: "1", Stage: "a", Testcase: 1, Verdict: Accepted},
+				{Mnr: "1", Stage: "a", Testcase: 2, Verdict: Rejected},
+				{Mnr: "2", Stage: "a", Testcase: 1, Verdict: Accepted},
+				{Mnr: "2", Stage: "b", Testcase: 1, Verdict: Malformed},
+			} {
+				s.Payload = []byte("{}")
+				s.SubmittedAt = time.Now()
+				if err := store.Add(ctx, &s); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			counts, err := store.CountVerdicts(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := map[string]VerdictCounts{
+				"a": {Accepted: 2, Rejected: 1},
+				"b": {Malformed: 1},
+			}
+			if !maps.EqualFunc(counts, want, maps.Equal) {
+				t.Errorf("CountVerdicts() = %v, want %v", counts, want)
+			}
+		})
+	}
+}
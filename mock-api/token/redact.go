@@ -0,0 +1,89 @@
+package token
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// RedactionMode decides how token values show up in logs.
+type RedactionMode int32
+
+const (
+	// RedactHash replaces a token with a short sha256 fingerprint.
+	RedactHash RedactionMode = iota
+	// RedactTruncate keeps the first few characters of a token.
+	RedactTruncate
+	// RedactNone logs tokens in full, only meant for local debugging.
+	RedactNone
+)
+
+const (
+	fingerprintLength = 12
+	truncateLength    = 4
+)
+
+var redactionMode atomic.Int32
+
+func (m RedactionMode) String() string {
+	switch m {
+	case RedactHash:
+		return "hash"
+	case RedactTruncate:
+		return "truncate"
+	case RedactNone:
+		return "none"
+	}
+	return fmt.Sprintf("RedactionMode(%d)", int32(m))
+}
+
+// ParseRedactionMode parses the textual representation of a RedactionMode.
+// An empty string yields the default RedactHash.
+func ParseRedactionMode(s string) (RedactionMode, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "hash":
+		return RedactHash, nil
+	case "truncate":
+		return RedactTruncate, nil
+	case "none", "off":
+		return RedactNone, nil
+	}
+	return RedactHash, fmt.Errorf("unknown token redaction mode %q", s)
+}
+
+// SetRedactionMode changes the redaction policy used by Redact.
+func SetRedactionMode(m RedactionMode) {
+	redactionMode.Store(int32(m))
+}
+
+// Fingerprint returns a stable, non-reversible identifier for a token value.
+// It does not depend on the configured redaction mode and is safe to persist.
+func Fingerprint(value string) string {
+	if value == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])[:fingerprintLength]
+}
+
+// Redact returns the representation of a token value that may be written to
+// logs, according to the configured redaction mode.
+func Redact(value string) string {
+	if value == "" {
+		return ""
+	}
+
+	switch RedactionMode(redactionMode.Load()) {
+	case RedactNone:
+		return value
+	case RedactTruncate:
+		if len(value) <= truncateLength {
+			return strings.Repeat("*", len(value))
+		}
+		return value[:truncateLength] + "..."
+	default:
+		return "sha256:" + Fingerprint(value)
+	}
+}
@@ -1,9 +1,13 @@
 package token
 
 import (
+	"context"
 	"crypto/md5"
+	"crypto/subtle"
 	"encoding/hex"
 	"errors"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -22,10 +26,22 @@ func (t TokenInfo) expired() bool {
 
 type TokenManager interface {
 	HasToken(string) bool
-	GetToken(string) (string, error)
+	// GetToken returns the token of a key, creating one if there is none
+	// or it expired. created tells whether the token is new.
+	GetToken(key string) (token string, created bool, err error)
 	ResetToken(string)
 	ValidateToken(string, string) (bool, error)
 	InvalidateToken(string)
+	Ping(context.Context) error
+	Active() []ActiveToken
+}
+
+// ActiveToken describes an issued token that has not expired, without
+// revealing its value.
+type ActiveToken struct {
+	Key         string    `json:"key"`
+	Fingerprint string    `json:"fingerprint"`
+	ValidUntil  time.Time `json:"validUntil"`
 }
 
 func generateToken(key string) string {
@@ -41,41 +57,76 @@ func generateToken(key string) string {
 }
 
 type TokenManagerInMemory struct {
+	mu     sync.Mutex
 	tokens map[string]TokenInfo
+	ttl    time.Duration
 }
 
-func NewTokenManagerInMemory() *TokenManagerInMemory {
+func NewTokenManagerInMemory(ttl time.Duration) *TokenManagerInMemory {
 	return &TokenManagerInMemory{
 		tokens: map[string]TokenInfo{},
+		ttl:    ttl,
 	}
 }
 
 func (tm *TokenManagerInMemory) HasToken(key string) bool {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
 	_, exists := tm.tokens[key]
 	return exists
 }
 
-func (tm *TokenManagerInMemory) GetToken(key string) (string, error) {
-	token, exists := tm.tokens[key]
-	if exists && !token.expired() {
-		return token.value, nil
+func (tm *TokenManagerInMemory) GetToken(key string) (string, bool, error) {
+	if value, exists := tm.current(key); exists {
+		return value, false, nil
+	}
+
+	// bcrypt is slow on purpose, generate without blocking other keys.
+	value := generateToken(key)
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	// A concurrent request for the same key may have been faster.
+	if token, exists := tm.tokens[key]; exists && !token.expired() {
+		return token.value, false, nil
 	}
-	token = TokenInfo{
-		value:      generateToken(key),
-		validUntil: time.Now().Add(time.Minute * 10),
+	token := TokenInfo{
+		value:      value,
+		validUntil: time.Now().Add(tm.ttl),
 		valid:      true,
 	}
 	tm.tokens[key] = token
-	log.Info().Str("token", token.value).Str("key", key).Msg("New token created")
-	return token.value, nil
+	log.Info().Str("token", Redact(token.value)).Str("key", key).Msg("New token created")
+	return token.value, true, nil
+}
+
+// current returns the unexpired token of key.
+func (tm *TokenManagerInMemory) current(key string) (string, bool) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	token, exists := tm.tokens[key]
+	if !exists || token.expired() {
+		return "", false
+	}
+	return token.value, true
 }
 
 func (tm *TokenManagerInMemory) ValidateToken(key string, tokenValue string) (bool, error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
 	token, exists := tm.tokens[key]
 	if !exists {
 		return false, errors.New("No token for key found")
 	}
 
+	if subtle.ConstantTimeCompare([]byte(token.value), []byte(tokenValue)) != 1 {
+		return false, errors.New("Token does not match")
+	}
+
 	if token.expired() {
 		log.Warn().Time("validUntil", token.validUntil).Time("time", time.Now())
 		return false, errors.New("Token has expired")
@@ -86,10 +137,16 @@ func (tm *TokenManagerInMemory) ValidateToken(key string, tokenValue string) (bo
 }
 
 func (tm *TokenManagerInMemory) ResetToken(key string) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
 	delete(tm.tokens, key)
 }
 
 func (tm *TokenManagerInMemory) InvalidateToken(key string) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
 	token, exists := tm.tokens[key]
 	if exists {
 		tokenClone := TokenInfo{
@@ -100,3 +157,35 @@ func (tm *TokenManagerInMemory) InvalidateToken(key string) {
 		tm.tokens[key] = tokenClone
 	}
 }
+
+func (tm *TokenManagerInMemory) Ping(ctx context.Context) error {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	if tm.tokens == nil {
+		return errors.New("token store is not initialized")
+	}
+	return ctx.Err()
+}
+
+// Active lists the valid, unexpired tokens ordered by key.
+func (tm *TokenManagerInMemory) Active() []ActiveToken {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	var active []ActiveToken
+	for key, token := range tm.tokens {
+		if !token.valid || token.expired() {
+			continue
+		}
+		active = append(active, ActiveToken{
+			Key:         key,
+			Fingerprint: Fingerprint(token.value),
+			ValidUntil:  token.validUntil,
+		})
+	}
+	sort.Slice(active, func(i, j int) bool {
+		return active[i].Key < active[j].Key
+	})
+	return active
+}
@@ -0,0 +1,55 @@
+package token
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGetTokenCreatesOneTokenPerKey(t *testing.T) {
+	tm := NewTokenManagerInMemory(time.Minute)
+
+	const requests = 8
+	values := make([]string, requests)
+	created := make([]bool, requests)
+	var wg sync.WaitGroup
+	for i := range requests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var err error
+			values[i], created[i], err = tm.GetToken("12345678")
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	creations := 0
+	for i := range requests {
+		if values[i] != values[0] {
+			t.Errorf("request %d got token %s, request 0 got %s", i, values[i], values[0])
+		}
+		if created[i] {
+			creations++
+		}
+	}
+	if creations != 1 {
+		t.Errorf("%d requests created a token, want 1", creations)
+	}
+	if ok, err := tm.ValidateToken("12345678", values[0]); !ok {
+		t.Errorf("ValidateToken() = %v", err)
+	}
+}
+
+func TestGetTokenReplacesExpiredToken(t *testing.T) {
+	tm := NewTokenManagerInMemory(time.Millisecond)
+	first, _, _ := tm.GetToken("12345678")
+	time.Sleep(5 * time.Millisecond)
+
+	second, created, err := tm.GetToken("12345678")
+	if err != nil || !created || second == first {
+		t.Errorf("GetToken() after expiry = %s, %v, %v, want a new token", second, created, err)
+	}
+}
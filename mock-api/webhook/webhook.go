@@ -0,0 +1,355 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Fancy11111/ase-prep/mock-api/events"
+	"github.com/rs/zerolog/log"
+)
+
+// Headers of a webhook request besides the Content-Type.
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderID        = "X-Webhook-ID"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Names of the notified events.
+const (
+	EventFinished           = "assignment.finished"
+	EventSubmissionAccepted = "submission.accepted"
+)
+
+const subscriptionBuffer = 1024
+
+// errQueueFull is the error of dead letters for events that arrived while
+// the subscription buffer was full.
+var errQueueFull = errors.New("webhook queue is full")
+
+type Endpoint struct {
+	URL    string
+	Secret string
+	// Accepted also sends EventSubmissionAccepted, not only EventFinished.
+	Accepted bool
+}
+
+type Options struct {
+	MaxAttempts int
+	// Backoff is the wait before the first retry, doubled for every
+	// further one.
+	Backoff time.Duration
+	// Timeout bounds a single attempt.
+	Timeout time.Duration
+	// DeadLetter is the JSON lines file failed deliveries are appended to,
+	// they are only logged if it is empty.
+	DeadLetter string
+}
+
+// Payload is the JSON body of a webhook request. The ID stays the same
+// across retries so receivers can drop duplicates, for EventFinished it is
+// derived from the mnr as every student finishes only once.
+type Payload struct {
+	ID         string    `json:"id"`
+	Event      string    `json:"event"`
+	Time       time.Time `json:"time"`
+	Mnr        string    `json:"mnr"`
+	Stage      string    `json:"stage,omitempty"`
+	Testcase   int       `json:"testcase,omitempty"`
+	Submission int64     `json:"submission,omitempty"`
+}
+
+// deadLetter is a line of the dead letter log.
+type deadLetter struct {
+	FailedAt time.Time       `json:"failedAt"`
+	URL      string          `json:"url"`
+	Attempts int             `json:"attempts"`
+	Error    string          `json:"error"`
+	Payload  json.RawMessage `json:"payload"`
+}
+
+// Dispatcher sends the finish events, and optionally the accepted
+// submissions, published on an event bus to the configured endpoints.
+type Dispatcher struct {
+	endpoints []Endpoint
+	opts      Options
+	client    *http.Client
+	// prefix makes payload IDs unique across restarts, the event IDs
+	// start at 1 again.
+	prefix string
+	// buffer is the size of the subscription buffer.
+	buffer int
+
+	deliveries sync.WaitGroup
+	deadMu     sync.Mutex
+}
+
+func New(endpoints []Endpoint, opts Options) *Dispatcher {
+	prefix := make([]byte, 4)
+	rand.Read(prefix)
+
+	return &Dispatcher{
+		endpoints: endpoints,
+		opts:      opts,
+		client:    &http.Client{Timeout: opts.Timeout},
+		prefix:    hex.EncodeToString(prefix),
+		buffer:    subscriptionBuffer,
+	}
+}
+
+// Start subscribes to bus and delivers its events in the background until
+// ctx is done. The pending deliveries then stop retrying and go to the dead
+// letter log, the returned channel is closed once they are through. Events
+// published after Start returned are either delivered or dead-lettered.
+func (d *Dispatcher) Start(ctx context.Context, bus *events.Bus) <-chan struct{} {
+	subscription := d.subscribe(bus)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		d.run(ctx, subscription)
+	}()
+	return done
+}
+
+// subscribe receives the events worth a webhook. Events arriving while the
+// buffer is full go to the dead letter log, as waiting for the deliveries
+// would block the handlers.
+func (d *Dispatcher) subscribe(bus *events.Bus) *events.Subscription {
+	return bus.SubscribeWithOverflow(events.Filter{
+		Types: []events.Type{events.Finished, events.SolutionSubmitted},
+	}, d.buffer, d.overflow)
+}
+
+func (d *Dispatcher) run(ctx context.Context, subscription *events.Subscription) {
+	for {
+		select {
+		case e := <-subscription.Events():
+			d.dispatch(ctx, e)
+		case <-ctx.Done():
+			// Closing stops new events and overflows, the queued ones
+			// get a single attempt as ctx is done.
+			subscription.Close()
+			for e := range subscription.Events() {
+				d.dispatch(ctx, e)
+			}
+			d.deliveries.Wait()
+			return
+		}
+	}
+}
+
+func (d *Dispatcher) overflow(e events.Event) {
+	payload, body, ok := d.payload(e)
+	if !ok {
+		return
+	}
+	log.Error().Str("event", payload.Event).Str("id", payload.ID).Msg("Webhook queue is full")
+	for _, endpoint := range d.endpointsFor(payload) {
+		d.writeDeadLetter(deadLetter{
+			FailedAt: time.Now(),
+			URL:      endpoint.URL,
+			Error:    errQueueFull.Error(),
+			Payload:  body,
+		})
+	}
+}
+
+func (d *Dispatcher) dispatch(ctx context.Context, e events.Event) {
+	payload, body, ok := d.payload(e)
+	if !ok {
+		return
+	}
+	for _, endpoint := range d.endpointsFor(payload) {
+		d.deliveries.Add(1)
+		go func() {
+			defer d.deliveries.Done()
+			d.deliver(ctx, endpoint, payload, body)
+		}()
+	}
+}
+
+// payload builds the webhook of e, ok is false if e is not worth one.
+func (d *Dispatcher) payload(e events.Event) (payload Payload, body []byte, ok bool) {
+	payload = Payload{
+		ID:         fmt.Sprintf("%s-%d", d.prefix, e.ID),
+		Time:       e.Time,
+		Mnr:        e.Mnr,
+		Stage:      e.Stage,
+		Testcase:   e.Testcase,
+		Submission: e.Submission,
+	}
+	switch {
+	case e.Type == events.Finished:
+		payload.Event = EventFinished
+		payload.ID = "finished-" + e.Mnr
+	case e.Type == events.SolutionSubmitted && e.Verdict == "accepted":
+		payload.Event = EventSubmissionAccepted
+	default:
+		return payload, nil, false
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Err(err).Msg("Could not marshal webhook payload")
+		return payload, nil, false
+	}
+	return payload, body, true
+}
+
+// endpointsFor returns the endpoints interested in payload.
+func (d *Dispatcher) endpointsFor(payload Payload) []Endpoint {
+	var endpoints []Endpoint
+	for _, endpoint := range d.endpoints {
+		if payload.Event == EventSubmissionAccepted && !endpoint.Accepted {
+			continue
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints
+}
+
+// deliver tries to send body until the endpoint accepts it, backing off
+// between the attempts.
+func (d *Dispatcher) deliver(ctx context.Context, endpoint Endpoint, payload Payload, body []byte) {
+	logger := log.With().
+		Str("webhook", endpoint.URL).
+		Str("event", payload.Event).
+		Str("id", payload.ID).
+		Logger()
+
+	backoff := d.opts.Backoff
+	attempt := 0
+	var err error
+	for {
+		attempt++
+		var retry bool
+		retry, err = d.send(ctx, endpoint, payload, body)
+		if err == nil {
+			logger.Info().Int("attempt", attempt).Msg("Webhook delivered")
+			return
+		}
+		logger.Warn().Err(err).Int("attempt", attempt).Msg("Webhook delivery failed")
+		if !retry || attempt >= d.opts.MaxAttempts {
+			break
+		}
+		if !sleep(ctx, backoff) {
+			err = fmt.Errorf("shutting down: %w", err)
+			break
+		}
+		backoff *= 2
+	}
+
+	logger.Error().Err(err).Int("attempts", attempt).Msg("Giving up on webhook")
+	d.writeDeadLetter(deadLetter{
+		FailedAt: time.Now(),
+		URL:      endpoint.URL,
+		Attempts: attempt,
+		Error:    err.Error(),
+		Payload:  body,
+	})
+}
+
+// sleep waits for d and reports false if ctx is done before.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// send makes a single attempt and reports whether a failure is worth a
+// retry. Client errors except 408 and 429 are not.
+func (d *Dispatcher) send(ctx context.Context, endpoint Endpoint, payload Payload, body []byte) (bool, error) {
+	// A started attempt is not aborted by the shutdown, the client timeout
+	// still applies.
+	req, err := http.NewRequestWithContext(context.WithoutCancel(ctx), http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "ase-prep-mock-api")
+	req.Header.Set(HeaderEvent, payload.Event)
+	req.Header.Set(HeaderID, payload.ID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(endpoint.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode == http.StatusTooManyRequests:
+		return true, fmt.Errorf("endpoint answered %s", resp.Status)
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return false, fmt.Errorf("endpoint answered %s", resp.Status)
+	}
+	return true, fmt.Errorf("endpoint answered %s", resp.Status)
+}
+
+func (d *Dispatcher) writeDeadLetter(letter deadLetter) {
+	if d.opts.DeadLetter == "" {
+		return
+	}
+
+	line, err := json.Marshal(letter)
+	if err != nil {
+		log.Err(err).Msg("Could not marshal dead letter")
+		return
+	}
+
+	d.deadMu.Lock()
+	defer d.deadMu.Unlock()
+
+	f, err := os.OpenFile(d.opts.DeadLetter, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		log.Err(err).Str("path", d.opts.DeadLetter).Msg("Could not open dead letter log")
+		return
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		log.Err(err).Str("path", d.opts.DeadLetter).Msg("Could not write dead letter")
+	}
+}
+
+// Sign computes the signature header value of a request: the hex encoded
+// HMAC-SHA256 of "<timestamp>.<body>" keyed with secret, prefixed by
+// "sha256=".
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks signature against the one expected by Sign in constant time.
+func Verify(secret string, timestamp string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
@@ -0,0 +1,218 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Fancy11111/ase-prep/mock-api/events"
+)
+
+const testSecret = "change-me"
+
+func TestSignVerify(t *testing.T) {
+	body := []byte(`{"event":"assignment.finished"}`)
+	signature := Sign(testSecret, "1700000000", body)
+
+	if !strings.HasPrefix(signature, "sha256=") {
+		t.Errorf("Sign() = %q, want a sha256= prefix", signature)
+	}
+	if !Verify(testSecret, "1700000000", body, signature) {
+		t.Error("Verify() rejected the signature of Sign()")
+	}
+	for name, verify := range map[string]bool{
+		"other secret":    Verify("other", "1700000000", body, signature),
+		"other timestamp": Verify(testSecret, "1700000001", body, signature),
+		"other body":      Verify(testSecret, "1700000000", []byte(`{}`), signature),
+	} {
+		if verify {
+			t.Errorf("Verify() accepted the signature with %s", name)
+		}
+	}
+}
+
+// receiver records the attempts of deliveries and answers them with the
+// next status of statuses, repeating the last one.
+type receiver struct {
+	t        *testing.T
+	mu       sync.Mutex
+	statuses []int
+	attempts []time.Time
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	if !Verify(testSecret, r.Header.Get(HeaderTimestamp), body, r.Header.Get(HeaderSignature)) {
+		rc.t.Errorf("attempt with invalid signature %q", r.Header.Get(HeaderSignature))
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	status := rc.statuses[min(len(rc.attempts), len(rc.statuses)-1)]
+	rc.attempts = append(rc.attempts, time.Now())
+	w.WriteHeader(status)
+}
+
+func (rc *receiver) count() int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return len(rc.attempts)
+}
+
+// deliverFinish runs a dispatcher against the receiver until it made
+// wantAttempts or the deadline passes, and returns the dead letter file.
+func deliverFinish(t *testing.T, rc *receiver, wantAttempts int) string {
+	t.Helper()
+
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	deadLetter := filepath.Join(t.TempDir(), "dead-letter.jsonl")
+	dispatcher := New([]Endpoint{{URL: server.URL, Secret: testSecret}}, Options{
+		MaxAttempts: 3,
+		Backoff:     20 * time.Millisecond,
+		Timeout:     time.Second,
+		DeadLetter:  deadLetter,
+	})
+
+	bus := events.NewBus()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := dispatcher.Start(ctx, bus)
+
+	bus.Publish(events.Event{Type: events.Finished, Mnr: "12345678"})
+
+	deadline := time.Now().Add(2 * time.Second)
+	for rc.count() < wantAttempts && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	// Give an unexpected further attempt the chance to show up.
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	<-done
+	return deadLetter
+}
+
+func TestRetriesServerErrorsWithBackoff(t *testing.T) {
+	rc := &receiver{t: t, statuses: []int{500, 503, 200}}
+	deadLetter := deliverFinish(t, rc, 3)
+
+	if got := rc.count(); got != 3 {
+		t.Fatalf("got %d attempts, want 3", got)
+	}
+	if first, second := rc.attempts[1].Sub(rc.attempts[0]), rc.attempts[2].Sub(rc.attempts[1]); first < 20*time.Millisecond || second < 40*time.Millisecond {
+		t.Errorf("waited %s and %s between the attempts, want at least 20ms and 40ms", first, second)
+	}
+	if _, err := os.Stat(deadLetter); !os.IsNotExist(err) {
+		t.Errorf("delivered webhook was written to the dead letter log: %v", err)
+	}
+}
+
+func TestDoesNotRetryClientErrors(t *testing.T) {
+	rc := &receiver{t: t, statuses: []int{400}}
+	deadLetter := deliverFinish(t, rc, 1)
+
+	if got := rc.count(); got != 1 {
+		t.Errorf("got %d attempts, want 1", got)
+	}
+	letters := readDeadLetters(t, deadLetter)
+	if len(letters) != 1 || letters[0].Attempts != 1 {
+		t.Fatalf("dead letters = %+v, want one after 1 attempt", letters)
+	}
+}
+
+func TestWritesDeadLetterAfterMaxAttempts(t *testing.T) {
+	rc := &receiver{t: t, statuses: []int{502}}
+	deadLetter := deliverFinish(t, rc, 3)
+
+	if got := rc.count(); got != 3 {
+		t.Errorf("got %d attempts, want 3", got)
+	}
+	letters := readDeadLetters(t, deadLetter)
+	if len(letters) != 1 {
+		t.Fatalf("got %d dead letters, want 1", len(letters))
+	}
+
+	letter := letters[0]
+	var payload Payload
+	if err := json.Unmarshal(letter.Payload, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if letter.Attempts != 3 || !strings.Contains(letter.Error, "502") {
+		t.Errorf("dead letter after %d attempts with %q, want 3 attempts with a 502", letter.Attempts, letter.Error)
+	}
+	if payload.Event != EventFinished || payload.Mnr != "12345678" || payload.ID != "finished-12345678" {
+		t.Errorf("dead letter payload = %+v", payload)
+	}
+}
+
+func TestFullQueueWritesDeadLetters(t *testing.T) {
+	rc := &receiver{t: t, statuses: []int{200}}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	deadLetter := filepath.Join(t.TempDir(), "dead-letter.jsonl")
+	dispatcher := New([]Endpoint{{URL: server.URL, Secret: testSecret}}, Options{
+		MaxAttempts: 1,
+		Backoff:     time.Millisecond,
+		Timeout:     time.Second,
+		DeadLetter:  deadLetter,
+	})
+	dispatcher.buffer = 1
+
+	// Nothing reads the subscription yet, so only the first event fits.
+	bus := events.NewBus()
+	subscription := dispatcher.subscribe(bus)
+	for _, mnr := range []string{"1", "2", "3"} {
+		bus.Publish(events.Event{Type: events.Finished, Mnr: mnr})
+	}
+	if dropped := subscription.Dropped(); dropped != 0 {
+		t.Errorf("%d events were dropped", dropped)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	dispatcher.run(ctx, subscription)
+
+	if got := rc.count(); got != 1 {
+		t.Errorf("got %d attempts, want 1 for the queued event", got)
+	}
+	letters := readDeadLetters(t, deadLetter)
+	if len(letters) != 2 {
+		t.Fatalf("got %d dead letters, want 2", len(letters))
+	}
+	for i, letter := range letters {
+		var payload Payload
+		if err := json.Unmarshal(letter.Payload, &payload); err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{"2", "3"}[i]; payload.Mnr != want || letter.Error != errQueueFull.Error() {
+			t.Errorf("dead letter %d for %s with %q, want one for %s with %q", i, payload.Mnr, letter.Error, want, errQueueFull)
+		}
+	}
+}
+
+func readDeadLetters(t *testing.T, path string) []deadLetter {
+	t.Helper()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var letters []deadLetter
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		var letter deadLetter
+		if err := json.Unmarshal([]byte(line), &letter); err != nil {
+			t.Fatalf("dead letter line %q: %v", line, err)
+		}
+		letters = append(letters, letter)
+	}
+	return letters
+}